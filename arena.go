@@ -0,0 +1,253 @@
+package main
+
+import (
+	"math/rand"
+)
+
+const (
+	arenaGenerator string = "arena"
+
+	arenaMinHeight int = 2
+	arenaMaxHeight int = 5
+
+	arenaGap float64 = 6
+	arenaGapChance int = 30
+	// Chance of a low building between the stairwell and the middle
+	arenaStepChance int = 50
+	arenaTableChance int = 20
+)
+
+// Rolled once for a building on the left half and used again for its mirror on the right.
+type arenaPlan struct {
+	gap float64
+	height int
+	color int
+
+	// Levels with stairs, and whether they climb toward the outside of the arena
+	stairs map[int]bool
+	stairsOutward bool
+	tables map[int]bool
+}
+
+func newArenaPlan(height int, color int) *arenaPlan {
+	return &arenaPlan {
+		height: height,
+		color: color,
+		stairs: make(map[int]bool),
+		tables: make(map[int]bool),
+	}
+}
+
+func (ap *arenaPlan) addTables(r *rand.Rand) {
+	for level := 1; level < ap.height; level += 1 {
+		if !ap.stairs[level] && !ap.stairs[level - 1] && r.Intn(100) < arenaTableChance {
+			ap.tables[level] = true
+		}
+	}
+}
+
+type arenaBuilding struct {
+	plan *arenaPlan
+	building *Building
+
+	// Which way the middle is, or unknownCardinal for the middle building
+	inward CardinalType
+	outermost bool
+}
+
+func sideDir(cardinal CardinalType) Vec2 {
+	if cardinal == leftCardinal {
+		return NewVec2(-1, 0)
+	}
+	return NewVec2(1, 0)
+}
+
+// A mirrored row of buildings around a shared middle. Each team spawns on an outer tower, and the
+// VIP goal sits on the middle roof so both sides are the same distance away.
+//
+// The tower and the stairwell next to it have stairs on alternating levels that climb away from
+// each other, so anyone can zigzag between them up to the tower roof. Everything further in is
+// no taller than the stairwell and is reached through doors or by dropping down.
+func buildArena(lf LevelFile, r *rand.Rand, palette []int, bg *BlockGrid) {
+	towerHeight := arenaMaxHeight - r.Intn(2)
+	tower := newArenaPlan(towerHeight, palette[0])
+	tower.stairsOutward = true
+	stairwell := newArenaPlan(towerHeight - 1, palette[1 % len(palette)])
+	for level := 1; level < towerHeight; level += 1 {
+		// The top floor of the tower always has stairs up to the spawn
+		if (towerHeight - 1 - level) % 2 == 0 {
+			tower.stairs[level] = true
+		} else if level < stairwell.height {
+			stairwell.stairs[level] = true
+		}
+	}
+	tower.addTables(r)
+	stairwell.addTables(r)
+
+	plans := []*arenaPlan{tower, stairwell}
+	innerHeight := stairwell.height
+	if r.Intn(100) < arenaStepChance {
+		step := newArenaPlan(arenaMinHeight + r.Intn(innerHeight - arenaMinHeight + 1), palette[2 % len(palette)])
+		if r.Intn(100) < arenaGapChance {
+			step.gap = arenaGap
+		}
+		step.addTables(r)
+		plans = append(plans, step)
+		innerHeight = step.height
+	}
+
+	middle := newArenaPlan(arenaMinHeight + r.Intn(innerHeight - arenaMinHeight + 1), palette[len(plans) % len(palette)])
+	if r.Intn(100) < arenaGapChance {
+		middle.gap = arenaGap
+	}
+	middle.addTables(r)
+
+	add := func(plan *arenaPlan, gap float64, inward CardinalType, outermost bool) arenaBuilding {
+		building := bg.AddBuilding(BuildingAttributes {
+			gap: gap,
+			blockType: archBlock,
+			color: plan.color,
+			secondaryColor: archWhite,
+			height: plan.height,
+		})
+		return arenaBuilding {
+			plan: plan,
+			building: building,
+			inward: inward,
+			outermost: outermost,
+		}
+	}
+
+	side := len(plans)
+	buildings := make([]arenaBuilding, 0, 2 * side + 1)
+	for i, plan := range(plans) {
+		buildings = append(buildings, add(plan, plan.gap, rightCardinal, i == 0))
+	}
+	buildings = append(buildings, add(middle, middle.gap, unknownCardinal, false))
+	for i := side - 1; i >= 0; i -= 1 {
+		// Gaps are stored on the building to the right, so each mirror takes its inner neighbor's
+		gap := middle.gap
+		if i < side - 1 {
+			gap = plans[i + 1].gap
+		}
+		buildings = append(buildings, add(plans[i], gap, leftCardinal, i == 0))
+	}
+
+	// Buildings touching each other, like in BlockGrid.Connect
+	neighbors := make([]map[CardinalType]*Building, len(buildings))
+	for i := range(buildings) {
+		neighbors[i] = make(map[CardinalType]*Building)
+		if i > 0 && buildings[i].building.attributes.gap == 0 {
+			neighbors[i][leftCardinal] = buildings[i - 1].building
+		}
+		if i < len(buildings) - 1 && buildings[i + 1].building.attributes.gap == 0 {
+			neighbors[i][rightCardinal] = buildings[i + 1].building
+		}
+	}
+
+	for _, ab := range(buildings) {
+		ab.addOpenings()
+	}
+	for i, ab := range(buildings) {
+		ab.addStairs(neighbors[i])
+	}
+	for i, ab := range(buildings) {
+		ab.connect(neighbors[i])
+	}
+
+	for _, ab := range(buildings) {
+		roof := ab.building.GetRoof()
+		if ab.outermost {
+			roof.LoadTemplate(weaponsBlockTemplate)
+
+			team := uint8(1)
+			if ab.inward == leftCardinal {
+				team = 2
+			}
+			dir := levelVec2(sideDir(ab.inward))
+			LevelObject {
+				Type: levelObjectType(spawnLevelObject),
+				Offset: levelVec2(NewVec2(0, 2)),
+				Dir: &dir,
+				Team: team,
+			}.addTo(roof)
+		}
+		if ab.inward == unknownCardinal {
+			LevelObject {
+				Type: levelObjectType(goalLevelObject),
+				Team: 1,
+			}.addTo(roof)
+		}
+	}
+}
+
+// Every level above the ground is open, except the outer walls of the arena.
+func (ab arenaBuilding) addOpenings() {
+	sides := []CardinalType{leftCardinal, rightCardinal}
+	if ab.outermost {
+		sides = []CardinalType{ab.inward}
+	}
+	for level := 1; level < len(ab.building.blocks); level += 1 {
+		ab.building.blocks[level].AddOpenings(sides...)
+	}
+}
+
+// Stairs fill the side they climb toward, so the door on that side is closed off.
+func (ab arenaBuilding) addStairs(neighbors map[CardinalType]*Building) {
+	building := ab.building
+	height := len(building.blocks)
+
+	climb := ab.inward
+	if ab.plan.stairsOutward {
+		climb = mirrorCardinal(ab.inward)
+	}
+
+	for level := 1; level < height; level += 1 {
+		block := building.blocks[level]
+
+		if ab.plan.stairs[level] && ab.inward != unknownCardinal {
+			stairs := NewLeftCardinal()
+			hole := bottomLeftCardinal
+			if climb == rightCardinal {
+				stairs = NewRightCardinal()
+				hole = bottomRightCardinal
+			}
+
+			block.LoadSidedTemplate(stairsSidedBlockTemplate, stairs)
+			block.RemoveOpenings(climb)
+			if neighbor := neighbors[climb]; neighbor != nil && level < len(neighbor.blocks) {
+				neighbor.blocks[level].RemoveOpenings(mirrorCardinal(climb))
+			}
+
+			if level + 1 < height {
+				building.blocks[level + 1].AddOpenings(hole)
+			} else {
+				building.GetRoof().AddOpenings(hole, climb)
+			}
+		}
+
+		if ab.plan.tables[level] {
+			block.LoadTemplate(tableBlockTemplate)
+		}
+	}
+}
+
+// Same rules as BlockGrid.Connect, applied to both sides.
+func (ab arenaBuilding) connect(neighbors map[CardinalType]*Building) {
+	building := ab.building
+	height := len(building.blocks)
+
+	for _, side := range([]CardinalType{leftCardinal, rightCardinal}) {
+		neighbor := neighbors[side]
+		for level := 1; level < height; level += 1 {
+			block := building.blocks[level]
+			if block.GetOpening(side) && (neighbor == nil || level > len(neighbor.blocks)) {
+				block.AddBalcony(sideDir(side))
+			}
+		}
+
+		if neighbor != nil && len(neighbor.blocks) > height && neighbor.blocks[height].GetOpening(mirrorCardinal(side)) {
+			building.GetRoof().AddOpenings(side)
+		}
+	}
+}
@@ -1,5 +1,9 @@
 package main
 
+import (
+	"math"
+)
+
 type TeamType uint8
 const (
 	unknownTeam TeamType = iota
@@ -13,6 +17,33 @@ var teamColors = map[uint8]int {
 	2: rightTeamColor,
 }
 
+// Teams past the first two are spread around the color wheel by the golden angle.
+func TeamColor(team uint8) int {
+	if color, ok := teamColors[team]; ok {
+		return color
+	}
+
+	hue := math.Mod(float64(team) * 137.508, 360) / 60
+	x := 1 - math.Abs(math.Mod(hue, 2) - 1)
+
+	var r, g, b float64
+	switch int(hue) {
+	case 0:
+		r, g, b = 1, x, 0
+	case 1:
+		r, g, b = x, 1, 0
+	case 2:
+		r, g, b = 0, 1, x
+	case 3:
+		r, g, b = 0, x, 1
+	case 4:
+		r, g, b = x, 0, 1
+	default:
+		r, g, b = 1, 0, x
+	}
+	return int(r * 0xff) << 16 | int(g * 0xff) << 8 | int(b * 0xff)
+}
+
 type Association struct {
 	owner SpacedId
 	ownerFlag *Flag
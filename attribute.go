@@ -17,6 +17,7 @@ const (
 	visibleAttribute
 	vipAttribute
 	fromLevelAttribute
+	carryingAttribute
 )
 
 type ByteAttributeType uint8
@@ -28,6 +29,8 @@ const (
 	stateByteAttribute
 	teamByteAttribute
 	openingByteAttribute
+	modeByteAttribute
+	voteByteAttribute
 
 	// TODO: not really attributes?
 	healthByteAttribute
@@ -12,12 +12,17 @@ const (
 	unknownBlockTemplate BlockTemplate = iota
 	weaponsBlockTemplate
 	tableBlockTemplate
+	cratesBlockTemplate
+	jumpPadBlockTemplate
 )
 
 type SidedBlockTemplate uint8
 const (
 	unknownSidedBlockTemplate SidedBlockTemplate = iota
 	stairsSidedBlockTemplate
+	ladderSidedBlockTemplate
+	sniperNestSidedBlockTemplate
+	elevatorSidedBlockTemplate
 )
 
 var blockSizes = map[SpaceType]map[BlockType]Vec2 {
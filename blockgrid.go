@@ -6,8 +6,39 @@ import (
 
 const (
 	buildingStartY float64 = -9.0
+
+	// Chance for a roof next to a taller building
+	jumpPadChance int = 25
+
+	// Chance for a platform to slide across a gap between roofs
+	slidingPlatformChance int = 50
+	slidingPlatformMinGap float64 = 6
+	// Keeps the platform above the balconies of the taller building
+	slidingPlatformMaxRise float64 = 3
+	slidingPlatformWidth float64 = 3
+	slidingPlatformSpeed float64 = 3
+)
+
+type BlockFeature uint8
+const (
+	emptyBlockFeature BlockFeature = iota
+	tableBlockFeature
+	cratesBlockFeature
+	ladderBlockFeature
+	sniperNestBlockFeature
+	elevatorBlockFeature
 )
 
+// Out of 100 for every block with a side opening and a solid floor
+var blockFeatureWeights = []int {
+	emptyBlockFeature: 52,
+	tableBlockFeature: 20,
+	cratesBlockFeature: 12,
+	ladderBlockFeature: 5,
+	sniperNestBlockFeature: 7,
+	elevatorBlockFeature: 4,
+}
+
 type BuildingAttributes struct {
 	gap float64
 	blockType BlockType
@@ -193,7 +224,6 @@ func (bg *BlockGrid) Connect(r *rand.Rand) {
 			roof.AddOpenings(rightCardinal)
 		}
 
-		/*
 		if !roof.AnyOpenings(bottomLeftCardinal, bottomRightCardinal, bottomCardinal) && nextBuilding != nil {
 			if len(nextBuilding.blocks) > len(building.blocks) + 1 {
 				roof.AddHut()
@@ -205,18 +235,110 @@ func (bg *BlockGrid) Connect(r *rand.Rand) {
 				roof.AddHut()
 			}
 		}
-		*/
 	}
 }
 
 func (bg *BlockGrid) Randomize(r *rand.Rand) {
-	for _, building := range(bg.buildings) {
-		for _, block := range(building.blocks) {
-			if block.AnyOpenings(leftCardinal, rightCardinal) && !block.AnyOpenings(bottomLeftCardinal, bottomCardinal, bottomRightCardinal) && r.Intn(100) < 20 {
+	for i, building := range(bg.buildings) {
+		for j, block := range(building.blocks) {
+			if !block.AnyOpenings(leftCardinal, rightCardinal) || block.AnyOpenings(bottomLeftCardinal, bottomCardinal, bottomRightCardinal) {
+				continue
+			}
+
+			weights := make([]int, len(blockFeatureWeights))
+			copy(weights, blockFeatureWeights)
+
+			// Ladders and elevators go up through a new hole in the block above
+			ladder := leftCardinal
+			if r.Intn(2) == 0 {
+				ladder = rightCardinal
+			}
+			if above := building.GetBlock(j + 1); above == nil || above.AnyOpenings(bottomLeftCardinal, bottomCardinal, bottomRightCardinal) || above.occupied.AnyBottom() || block.occupied.Get(bottomSide(ladder)) {
+				weights[ladderBlockFeature] = 0
+				weights[elevatorBlockFeature] = 0
+			}
+
+			// Look out whichever side is open
+			nest := ladder
+			if !block.GetOpening(nest) {
+				nest = mirrorCardinal(nest)
+			}
+			if block.occupied.Get(bottomSide(nest)) {
+				weights[sniperNestBlockFeature] = 0
+			}
+
+			switch BlockFeature(PickWeighted(r, weights...)) {
+			case tableBlockFeature:
 				block.LoadTemplate(tableBlockTemplate)
+			case cratesBlockFeature:
+				block.LoadTemplate(cratesBlockTemplate)
+			case ladderBlockFeature:
+				block.LoadSidedTemplate(ladderSidedBlockTemplate, sideCardinal(ladder))
+				block.AddOpenings(topCardinal)
+				building.blocks[j + 1].AddOpenings(bottomSide(ladder))
+			case sniperNestBlockFeature:
+				block.LoadSidedTemplate(sniperNestSidedBlockTemplate, sideCardinal(nest))
+			case elevatorBlockFeature:
+				block.LoadSidedTemplate(elevatorSidedBlockTemplate, sideCardinal(ladder))
+				block.AddOpenings(topCardinal)
+				building.blocks[j + 1].AddOpenings(bottomSide(ladder))
 			}
 		}
+
+		// Jump pads help get up to a taller neighbor
+		taller := (i > 0 && len(bg.buildings[i - 1].blocks) > len(building.blocks)) ||
+			(i < len(bg.buildings) - 1 && len(bg.buildings[i + 1].blocks) > len(building.blocks))
+		if taller && r.Intn(100) < jumpPadChance {
+			building.GetRoof().LoadTemplate(jumpPadBlockTemplate)
+		}
+
+		if i > 0 && building.attributes.gap >= slidingPlatformMinGap && r.Intn(100) < slidingPlatformChance {
+			bg.addSlidingPlatform(bg.buildings[i - 1], building)
+		}
+	}
+}
+
+// Slides back and forth across the gap, from level with one roof to level with the other.
+func (bg *BlockGrid) addSlidingPlatform(left *Building, right *Building) {
+	leftRoof := left.GetRoof()
+	rightRoof := right.GetRoof()
+
+	start := leftRoof.PosC(bottomRightCardinal)
+	start.X += slidingPlatformWidth / 2
+	start.Y += leftRoof.GetThickness() / 2
+	end := rightRoof.PosC(bottomLeftCardinal)
+	end.X -= slidingPlatformWidth / 2
+	end.Y += rightRoof.GetThickness() / 2
+
+	if Abs(end.Y - start.Y) > slidingPlatformMaxRise {
+		return
+	}
+
+	platform := NewWall(NewInit(Id(wallSpace, 0), start, NewVec2(slidingPlatformWidth, leftRoof.GetThickness())))
+	platform.SetByteAttribute(typeByteAttribute, uint8(platformWall))
+	platform.AddAttribute(visibleAttribute)
+	if color, ok := leftRoof.GetIntAttribute(colorIntAttribute); ok {
+		platform.SetIntAttribute(colorIntAttribute, color)
+	}
+	platform.SetSpeed(slidingPlatformSpeed)
+	platform.AddWaypoint(start)
+	platform.AddWaypoint(end)
+
+	leftRoof.AddObject(platform)
+}
+
+func sideCardinal(side CardinalType) Cardinal {
+	if side == leftCardinal {
+		return NewLeftCardinal()
+	}
+	return NewRightCardinal()
+}
+
+func bottomSide(side CardinalType) CardinalType {
+	if side == leftCardinal {
+		return bottomLeftCardinal
 	}
+	return bottomRightCardinal
 }
 
 func (bg *BlockGrid) UpsertToGrid(g *Grid) {
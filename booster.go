@@ -47,7 +47,7 @@ func (b *Booster) Update(grid *Grid, now time.Time) {
 		// b.doubleJumpReset = true
 	}
 
-	if !b.canBoost || b.timer.On() {
+	if !b.canBoost || b.timer.On(now) {
 		if b.doubleJumpReset && !enabled && !player.HasAttribute(canDoubleJumpAttribute) {
 			b.canBoost = true
 			b.doubleJumpReset = false
@@ -74,7 +74,7 @@ func (b *Booster) Update(grid *Grid, now time.Time) {
 
 	b.state = activePartState
 	b.canBoost = false
-	b.timer.Start()
+	b.timer.Start(now)
 }
 
 func (b Booster) OnDelete(grid *Grid) {}
\ No newline at end of file
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	botSightRange float64 = 20
+	botEscortDistance float64 = 3
+	botPickupDistance float64 = 0.8
+	botStuckTime time.Duration = 400 * time.Millisecond
+)
+
+// Drives a player with no client by generating a KeyMsg every frame.
+type Bot struct {
+	id IdType
+	seqNum SeqNumType
+
+	lastPos Vec2
+	lastJump bool
+	stuckTimer Timer
+}
+
+func NewBot(id IdType) *Bot {
+	return &Bot {
+		id: id,
+		seqNum: 0,
+
+		lastPos: NewVec2(0, 0),
+		lastJump: false,
+		stuckTimer: NewTimer(botStuckTime),
+	}
+}
+
+func (b *Bot) GetKeyMsg(grid *Grid, now time.Time) (KeyMsg, bool) {
+	object := grid.Get(Id(playerSpace, b.id))
+	if object == nil || object.HasAttribute(deadAttribute) {
+		return KeyMsg{}, false
+	}
+	player := object.(*Player)
+	pos := player.Pos()
+
+	b.seqNum++
+	msg := KeyMsg {
+		T: keyType,
+		S: b.seqNum,
+		K: make([]KeyType, 0),
+		M: pos,
+		D: player.Dir(),
+	}
+
+	dest, interact := b.getDestination(grid, player)
+	if interact {
+		msg.K = append(msg.K, interactKey)
+	}
+
+	// Move towards the destination, jumping over anything in the way
+	dx := dest.X - pos.X
+	moving := false
+	if Abs(dx) > 0.5 {
+		moving = true
+		if dx < 0 {
+			msg.K = append(msg.K, leftKey)
+		} else {
+			msg.K = append(msg.K, rightKey)
+		}
+	}
+
+	if !moving || Abs(pos.X - b.lastPos.X) > 0.01 {
+		b.stuckTimer.Stop()
+	} else if !b.stuckTimer.Started() {
+		b.stuckTimer.Start(now)
+	}
+	b.lastPos = pos
+
+	needJump := b.stuckTimer.Finished(now) || (dest.Y > pos.Y + 1.5 && Abs(dx) < 6)
+	if moving && b.blocked(grid, player, FSign(dx)) {
+		needJump = true
+	}
+
+	jump := false
+	if needJump {
+		if player.grounded {
+			jump = true
+		} else if player.Vel().Y > 0 {
+			// Hold for a higher jump
+			jump = b.lastJump
+		} else {
+			// Release then press again to double jump
+			jump = !b.lastJump
+		}
+	}
+	if jump {
+		msg.K = append(msg.K, jumpKey)
+	}
+	b.lastJump = jump
+
+	// Aim and shoot at the closest enemy in sight, otherwise look where we're going
+	aim := dest
+	if enemy := b.getTarget(grid, player); enemy != nil {
+		aim = enemy.Pos()
+		if player.weapon != nil {
+			msg.K = append(msg.K, mouseClick)
+		}
+	}
+	dir := aim
+	dir.Sub(pos, 1.0)
+	if !dir.IsZero() {
+		dir.Normalize()
+		msg.M = aim
+		msg.D = dir
+	}
+
+	return msg, true
+}
+
+// Returns where the bot wants to go and whether it should interact when it gets there.
+func (b *Bot) getDestination(grid *Grid, player *Player) (Vec2, bool) {
+	pos := player.Pos()
+	team, _ := player.GetByteAttribute(teamByteAttribute)
+	state, _ := grid.GetGameState()
+
+	if state == lobbyGameState {
+		if portal := b.getTeamPortal(grid, team); portal != nil {
+			return portal.Pos(), false
+		}
+	}
+
+	if player.weapon == nil {
+		if pickup := b.getClosest(pos, grid.GetObjects(pickupSpace)); pickup != nil {
+			return pickup.Pos(), pos.Distance(pickup.Pos()) < botPickupDistance + pickup.Dim().X / 2
+		}
+	}
+
+	if state == activeGameState {
+		switch grid.GetGameModeId() {
+		case vipGameMode:
+			if dest, ok := b.getVipDestination(grid, player); ok {
+				return dest, false
+			}
+		case ctfGameMode:
+			if dest, ok := b.getFlagDestination(grid, player); ok {
+				return dest, false
+			}
+		}
+	}
+
+	if enemy := b.getClosest(pos, b.getEnemies(grid, player)); enemy != nil {
+		return enemy.Pos(), false
+	}
+	return pos, false
+}
+
+// In the lobby, join whichever team is smaller.
+func (b *Bot) getTeamPortal(grid *Grid, team uint8) Object {
+	counts := make(map[uint8]int)
+	for _, player := range(grid.GetObjects(playerSpace)) {
+		if playerTeam, ok := player.GetByteAttribute(teamByteAttribute); ok && playerTeam > 0 {
+			counts[playerTeam] += 1
+		}
+	}
+
+	var best Object
+	bestCount := 0
+	for _, portal := range(sortById(grid.GetObjects(portalSpace))) {
+		portalTeam, ok := portal.GetByteAttribute(teamByteAttribute)
+		if !ok || portalTeam == 0 {
+			continue
+		}
+		if portalTeam == team {
+			return nil
+		}
+
+		count := counts[portalTeam]
+		if best == nil || count < bestCount {
+			best = portal
+			bestCount = count
+		}
+	}
+	return best
+}
+
+// The VIP heads for the goal, teammates escort the VIP, and everyone else hunts the VIP.
+func (b *Bot) getVipDestination(grid *Grid, player *Player) (Vec2, bool) {
+	pos := player.Pos()
+	team, _ := player.GetByteAttribute(teamByteAttribute)
+
+	if player.HasAttribute(vipAttribute) {
+		for _, goal := range(grid.GetObjects(goalSpace)) {
+			if goalTeam, ok := goal.GetByteAttribute(teamByteAttribute); ok && goalTeam == team {
+				return goal.Pos(), true
+			}
+		}
+		return pos, false
+	}
+
+	vip := b.getVip(grid)
+	if vip == nil {
+		return pos, false
+	}
+
+	vipTeam, _ := vip.GetByteAttribute(teamByteAttribute)
+	if vipTeam == team && pos.Distance(vip.Pos()) < botEscortDistance {
+		return pos, true
+	}
+	return vip.Pos(), true
+}
+
+// Grab the enemy flag and bring it home.
+func (b *Bot) getFlagDestination(grid *Grid, player *Player) (Vec2, bool) {
+	team, _ := player.GetByteAttribute(teamByteAttribute)
+
+	for _, object := range(grid.GetObjects(teamFlagSpace)) {
+		flag := object.(*TeamFlag)
+		if player.HasAttribute(carryingAttribute) {
+			if flag.GetTeam() == team {
+				return flag.home, true
+			}
+		} else if flag.GetTeam() != team && flag.GetCarrier().Invalid() {
+			return flag.Pos(), true
+		}
+	}
+	return player.Pos(), false
+}
+
+func (b *Bot) getVip(grid *Grid) Object {
+	for _, player := range(grid.GetObjects(playerSpace)) {
+		if player.HasAttribute(vipAttribute) && !player.HasAttribute(deadAttribute) {
+			return player
+		}
+	}
+	return nil
+}
+
+func (b *Bot) getEnemies(grid *Grid, player *Player) map[IdType]Object {
+	enemies := make(map[IdType]Object)
+	team, _ := player.GetByteAttribute(teamByteAttribute)
+	if team == 0 {
+		return enemies
+	}
+
+	for id, other := range(grid.GetObjects(playerSpace)) {
+		if other.HasAttribute(deadAttribute) {
+			continue
+		}
+		if otherTeam, _ := other.GetByteAttribute(teamByteAttribute); otherTeam == 0 || otherTeam == team {
+			continue
+		}
+		enemies[id] = other
+	}
+	return enemies
+}
+
+// Returns the enemy to shoot at, preferring an enemy VIP.
+func (b *Bot) getTarget(grid *Grid, player *Player) Object {
+	pos := player.Pos()
+	visible := make(map[IdType]Object)
+	for id, enemy := range(b.getEnemies(grid, player)) {
+		if pos.Distance(enemy.Pos()) > botSightRange || !b.canSee(grid, pos, enemy.Pos()) {
+			continue
+		}
+		if enemy.HasAttribute(vipAttribute) {
+			return enemy
+		}
+		visible[id] = enemy
+	}
+	return b.getClosest(pos, visible)
+}
+
+func (b *Bot) getClosest(pos Vec2, objects map[IdType]Object) Object {
+	var closest Object
+	for _, object := range(sortById(objects)) {
+		if closest == nil || pos.DistanceSquared(object.Pos()) < pos.DistanceSquared(closest.Pos()) {
+			closest = object
+		}
+	}
+	return closest
+}
+
+// Check for a solid wall right in front of the bot's feet.
+func (b *Bot) blocked(grid *Grid, player *Player, sign float64) bool {
+	origin := player.Pos()
+	origin.Y += -player.Dim().Y / 2 + 0.2
+	return b.hitsWall(grid, NewLine(origin, NewVec2(sign * (player.Dim().X / 2 + 0.6), 0)))
+}
+
+func (b *Bot) canSee(grid *Grid, from Vec2, to Vec2) bool {
+	ray := to
+	ray.Sub(from, 1.0)
+	if ray.IsZero() {
+		return true
+	}
+	return !b.hitsWall(grid, NewLine(from, ray))
+}
+
+// Platforms can be jumped and shot through, so they don't count.
+func (b *Bot) hitsWall(grid *Grid, line Line) bool {
+	for _, wall := range(grid.GetObjects(wallSpace)) {
+		if wallType, ok := wall.GetByteAttribute(typeByteAttribute); ok && WallType(wallType) == platformWall {
+			continue
+		}
+		if wall.GetProfile().Intersects(line).hit {
+			return true
+		}
+	}
+	return false
+}
@@ -14,6 +14,21 @@ const (
 	topRightCardinal
 )
 
+// Swaps left and right, leaving top and bottom alone.
+func mirrorCardinal(cardinal CardinalType) CardinalType {
+	switch (cardinal) {
+	case leftCardinal:
+		return rightCardinal
+	case rightCardinal:
+		return leftCardinal
+	case bottomLeftCardinal:
+		return bottomRightCardinal
+	case bottomRightCardinal:
+		return bottomLeftCardinal
+	}
+	return cardinal
+}
+
 type Cardinal struct {
 	mask uint8
 }
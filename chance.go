@@ -21,6 +21,26 @@ func NewGrowingChance(r *rand.Rand, min int, increment int) Chance {
 	}
 }
 
+// Picks an index with odds proportional to its weight, or -1 if all weights are zero.
+func PickWeighted(r *rand.Rand, weights ...int) int {
+	total := 0
+	for _, weight := range(weights) {
+		total += weight
+	}
+	if total <= 0 {
+		return -1
+	}
+
+	roll := r.Intn(total)
+	for i, weight := range(weights) {
+		if roll < weight {
+			return i
+		}
+		roll -= weight
+	}
+	return -1
+}
+
 func (c* Chance) Roll() bool {
 	if c.r.Intn(100) < c.current {
 		c.current = c.min
@@ -1,17 +1,109 @@
 package main
 
 import (
+	"errors"
+	"os"
+	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 const (
 	maxChatMsgs int = 16
 	maxChatMsgLength int = 256
+
+	// Each client can send a burst of messages, then one every chatRefillTime
+	chatBurst float64 = 5
+	chatRefillTime time.Duration = 1500 * time.Millisecond
+
+	// Comma separated list of words to mask in chat
+	chatFilterEnv string = "CHAT_FILTER_WORDS"
 )
 
+var (
+	errChatMuted = errors.New("You are muted in this room, your message was not sent.")
+	errChatRateLimited = errors.New("You are sending messages too quickly, your message was not sent.")
+)
+
+var chatFilter = NewWordFilter(strings.Split(os.Getenv(chatFilterEnv), ","))
+
+type TokenBucket struct {
+	tokens float64
+	capacity float64
+	refillTime time.Duration
+	last time.Time
+}
+
+func NewTokenBucket(capacity float64, refillTime time.Duration) *TokenBucket {
+	return &TokenBucket {
+		tokens: capacity,
+		capacity: capacity,
+		refillTime: refillTime,
+		last: time.Time{},
+	}
+}
+
+// Returns true and uses a token if one is available.
+func (tb *TokenBucket) Take(now time.Time) bool {
+	if !tb.last.IsZero() {
+		tb.tokens += float64(now.Sub(tb.last)) / float64(tb.refillTime)
+		if tb.tokens > tb.capacity {
+			tb.tokens = tb.capacity
+		}
+	}
+	tb.last = now
+
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens -= 1
+	return true
+}
+
+// Masks whole words regardless of case. An empty filter leaves messages alone.
+type WordFilter struct {
+	regex *regexp.Regexp
+}
+
+func NewWordFilter(words []string) *WordFilter {
+	patterns := make([]string, 0, len(words))
+	for _, word := range(words) {
+		word = strings.TrimSpace(word)
+		if len(word) == 0 {
+			continue
+		}
+		patterns = append(patterns, regexp.QuoteMeta(word))
+	}
+
+	if len(patterns) == 0 {
+		return &WordFilter{}
+	}
+	return &WordFilter {
+		regex: regexp.MustCompile(`(?i)\b(` + strings.Join(patterns, "|") + `)\b`),
+	}
+}
+
+func (wf WordFilter) Filter(message string) string {
+	if wf.regex == nil {
+		return message
+	}
+	return wf.regex.ReplaceAllStringFunc(message, func(match string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(match))
+	})
+}
+
 type Chat struct {
 	chatQueue []ChatMsg
 	replacer *strings.Replacer
+	filter *WordFilter
+
+	// Kept after clients leave so reconnecting doesn't reset them
+	buckets map[IdType]*TokenBucket
+
+	// IDs can only be reclaimed with their signed reconnect token, so a mute sticks to whoever
+	// holds the token and not to anyone else who joins later.
+	muted map[IdType]bool
 }
 
 func NewChat() *Chat {
@@ -29,7 +121,40 @@ func NewChat() *Chat {
 	return &Chat {
 		chatQueue: make([]ChatMsg, 0),
 		replacer: replacer,
+		filter: chatFilter,
+		buckets: make(map[IdType]*TokenBucket),
+		muted: make(map[IdType]bool),
+	}
+}
+
+func (c *Chat) Mute(id IdType) {
+	c.muted[id] = true
+}
+
+func (c *Chat) Unmute(id IdType) {
+	delete(c.muted, id)
+}
+
+func (c Chat) IsMuted(id IdType) bool {
+	return c.muted[id]
+}
+
+// Checked for every chat message including commands. Returns an error explaining why the message
+// was dropped, if it was.
+func (c *Chat) Allow(client *Client, now time.Time) error {
+	if c.IsMuted(client.id) {
+		return errChatMuted
+	}
+
+	bucket, ok := c.buckets[client.id]
+	if !ok {
+		bucket = NewTokenBucket(chatBurst, chatRefillTime)
+		c.buckets[client.id] = bucket
+	}
+	if !bucket.Take(now) {
+		return errChatRateLimited
 	}
+	return nil
 }
 
 func (c *Chat) ProcessChatMsg(client *Client, msg ChatMsg) ChatMsg {
@@ -37,6 +162,7 @@ func (c *Chat) ProcessChatMsg(client *Client, msg ChatMsg) ChatMsg {
 	if len(newMsg) > maxChatMsgLength {
 		newMsg = newMsg[:maxChatMsgLength]
 	}
+	newMsg = c.filter.Filter(newMsg)
 
 	outMsg := ChatMsg {
 		T: chatType,
@@ -27,26 +27,57 @@ type Client struct {
 	id IdType
 	name string
 	voice bool
+
+	// ID the client had before reconnecting, or -1. Only honored with a valid token.
+	requestedId int
+	reconnectToken string
+
+	// Saved from a previous connection, restored once the player joins
+	identity *Identity
+
+	// Spectators don't have a player and follow someone else's instead
+	spectator bool
+	target IdType
+
+	// Last object data the client received, used as the baseline for deltas
+	ack SeqNumType
+
+	// How object data is encoded for this client
+	codec CodecType
+
+	// Round trip time used for lag compensation
+	pinger Pinger
 }
 
-func NewClient(room* Room, ws *websocket.Conn, name string, id IdType) *Client {
+// The room assigns the ID and starts reading from the socket once the client is registered.
+func NewClient(room* Room, ws *websocket.Conn, name string, requestedId int) *Client {
 	client := &Client {
 		room: room,
 		ws: ws,
 		wrtc: nil,
 		dc: nil,
 
-		id: id,
+		id: 0,
 		name: name,
 		voice: false,
+		requestedId: requestedId,
+
+		spectator: false,
+		target: 0,
+
+		ack: 0,
+		codec: packCodec,
+		pinger: NewPinger(),
 	}
-	go client.run()
 	return client
 }
 
 func (c *Client) run() {
 	defer func() {
-		c.room.unregister <- c
+		select {
+		case c.room.unregister <- c:
+		case <-c.room.done:
+		}
 	}()
 
 	for {
@@ -63,7 +94,11 @@ func (c *Client) run() {
 			b: b,
 			client: c,
 		}
-		c.room.incoming <- imsg
+		select {
+		case c.room.incoming <- imsg:
+		case <-c.room.done:
+			return
+		}
 	}
 }
 
@@ -75,6 +110,38 @@ func (c *Client) GetClientData() ClientData {
 	return ClientData {
 		Id: c.id,
 		Name: c.name,
+		Spectator: c.spectator,
+	}
+}
+
+func (c *Client) SetSpectator(spectator bool) {
+	c.spectator = spectator
+}
+
+func (c *Client) IsSpectator() bool {
+	return c.spectator
+}
+
+func (c *Client) SetReconnectToken(token string) {
+	c.reconnectToken = token
+}
+
+func (c *Client) SetCodec(codec CodecType) {
+	c.codec = codec
+}
+
+func (c *Client) GetCodec() CodecType {
+	return c.codec
+}
+
+func (c *Client) GetAck() SeqNumType {
+	return c.ack
+}
+
+// Acks can arrive out of order over the data channel
+func (c *Client) Ack(seqNum SeqNumType, current SeqNumType) {
+	if seqNum > c.ack && seqNum <= current {
+		c.ack = seqNum
 	}
 }
 
@@ -158,7 +225,10 @@ func (c *Client) InitWebRTC(onSuccess func()) error {
 			b: msg.Data,
 			client: c,
 		}
-		c.room.incoming <- imsg
+		select {
+		case c.room.incoming <- imsg:
+		case <-c.room.done:
+		}
 	})
 
 	c.wrtc.OnICECandidate(func(ice *webrtc.ICECandidate) {
@@ -105,6 +105,12 @@ export class CameraController {
 		}
 	}
 
+	// Watch a specific object, falls back to any player if it goes away
+	follow(space : number, id : number) {
+		this._mode = CameraMode.ANY_PLAYER;
+		this._object = game.sceneMap().get(space, id);
+	}
+
 	seek(inc : number) {
 		this._seek = inc > 0 ? 1 : -1;
 		this._index += inc;
@@ -27,6 +27,7 @@ export class ChatHandler implements InterfaceHandler {
 		this._messageInputElm.style.width = this._chatElm.offsetWidth + "px";
 
 		connection.addHandler(chatType, (msg : { [k: string]: any }) => { this.chat(msg) })
+		connection.addHandler(shutdownType, (msg : { [k: string]: any }) => { this.print(msg.M) })
 		document.addEventListener("keydown", (e : any) => {
 			if (e.repeat) return;
 
@@ -65,10 +66,13 @@ export class ChatHandler implements InterfaceHandler {
 		}
 	}
 
-	print(message : string) : void {
+	print(message : string, system? : boolean) : void {
 		this.showChat();
 		const messageSpan = Html.span();
 		messageSpan.textContent = message;
+		if (system) {
+			Html.italic(messageSpan);
+		}
 
 		this._chatElm.append(messageSpan);
 		this._chatElm.append(Html.br());
@@ -85,10 +89,11 @@ export class ChatHandler implements InterfaceHandler {
 		}, 10000);
 	}
 
-	private command(message : string) {
+	// Returns false if the command should be handled by the server instead
+	private command(message : string) : boolean {
 		const pieces = message.trim().split(" ");
 		if (pieces.length === 0) {
-			return;
+			return true;
 		}
 
 		switch (pieces[0].toLowerCase()) {
@@ -130,6 +135,15 @@ export class ChatHandler implements InterfaceHandler {
 			case "player": 
 				renderer.cameraController().setMode(CameraMode.PLAYER);
 				break;
+			case "next":
+			case "prev":
+				connection.send({
+					T: spectateType,
+					Spectate: {
+						D: pieces[1].toLowerCase() === "next" ? 1 : -1,
+					},
+				});
+				break;
 			default:
 				ui.print("Unsupported spectate option: " + pieces[1]);
 			}
@@ -153,8 +167,9 @@ export class ChatHandler implements InterfaceHandler {
 			game.sceneMap().scene().overrideMaterial = null;
 			break;
 		default:
-			ui.print("Unknown command: " + message);
+			return false;
 		}
+		return true;
 	}
 
 	private chatKeyPressed() : void {
@@ -171,8 +186,7 @@ export class ChatHandler implements InterfaceHandler {
 			return;
 		}
 
-		if (message.startsWith("/")) {
-			this.command(message);
+		if (message.startsWith("/") && this.command(message)) {
 			this._messageInputElm.value = "";
 			ui.changeInputMode(InputMode.GAME);
 			return;
@@ -199,14 +213,19 @@ export class ChatHandler implements InterfaceHandler {
 	}
 
 	private chat(msg : { [k: string]: any }) {
+		const message = msg.M;
+		if (!Util.defined(message) || message.length === 0) return;
+
+		if (msg.Id === systemChatId) {
+			this.print(message, true);
+			return;
+		}
+
 		if (!ui.hasClient(msg.Id)) {
 			return;
 		}
 
 		const name = ui.getClientName(msg.Id);
-		const message = msg.M;
-
-		if (!Util.defined(message) || message.length === 0) return;
 
 		const nameSpan = Html.span();
 		Html.bold(nameSpan);
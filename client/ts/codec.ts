@@ -0,0 +1,113 @@
+import { decode } from "@msgpack/msgpack"
+
+// Decodes object data encoded by the server's compact codec, see codec.go
+export namespace Codec {
+	const int16Vec2 = 2;
+	const int32Vec2 = 4;
+
+	class Reader {
+		private _view : DataView;
+		private _bytes : Uint8Array;
+		private _offset : number;
+
+		constructor(bytes : Uint8Array) {
+			this._view = new DataView(bytes.buffer, bytes.byteOffset, bytes.byteLength);
+			this._bytes = bytes;
+			this._offset = 0;
+		}
+
+		byte() : number {
+			return this._view.getUint8(this._offset++);
+		}
+
+		// Masks can be wider than 32 bits, so avoid bitwise ops
+		uvarint() : number {
+			let value = 0;
+			let scale = 1;
+			while (true) {
+				const b = this.byte();
+				value += (b & 0x7f) * scale;
+				if ((b & 0x80) === 0) {
+					return value;
+				}
+				scale *= 128;
+			}
+		}
+
+		int16() : number {
+			const value = this._view.getInt16(this._offset, true);
+			this._offset += 2;
+			return value;
+		}
+
+		int32() : number {
+			const value = this._view.getInt32(this._offset, true);
+			this._offset += 4;
+			return value;
+		}
+
+		packed() : any {
+			const length = this.uvarint();
+			const value = decode(this._bytes.subarray(this._offset, this._offset + length));
+			this._offset += length;
+			return value;
+		}
+	}
+
+	export function decodeObjectPropMap(bytes : Uint8Array) : { [k: string]: any } {
+		const reader = new Reader(bytes);
+		const objects = {};
+
+		const numSpaces = reader.uvarint();
+		for (let i = 0; i < numSpaces; ++i) {
+			const space = reader.byte();
+			const numObjects = reader.uvarint();
+			objects[space] = {};
+
+			for (let j = 0; j < numObjects; ++j) {
+				const id = reader.uvarint();
+				objects[space][id] = decodePropMap(reader);
+			}
+		}
+		return objects;
+	}
+
+	function decodePropMap(reader : Reader) : { [k: string]: any } {
+		const props = {};
+		let mask = reader.uvarint();
+		for (let prop = 0; mask > 0; ++prop, mask = Math.floor(mask / 2)) {
+			if (mask % 2 === 0) {
+				continue;
+			}
+
+			if (isVec2Prop(prop)) {
+				props[prop] = decodeVec2(reader);
+			} else {
+				props[prop] = reader.packed();
+			}
+		}
+		return props;
+	}
+
+	// Props are globals set by WASM, so they can't be captured when the module loads
+	function isVec2Prop(prop : number) : boolean {
+		return prop === dimProp || prop === posProp || prop === velProp || prop === accProp || prop === jerkProp || prop === dirProp;
+	}
+
+	function decodeVec2(reader : Reader) : any {
+		const header = reader.byte();
+		const width = header & 0xf;
+		const scale = 1 << (header >> 4);
+
+		if (width === int16Vec2) {
+			const x = reader.int16();
+			const y = reader.int16();
+			return { X: x / scale, Y: y / scale };
+		} else if (width === int32Vec2) {
+			const x = reader.int32();
+			const y = reader.int32();
+			return { X: x / scale, Y: y / scale };
+		}
+		return reader.packed();
+	}
+}
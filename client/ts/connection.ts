@@ -23,6 +23,7 @@ class Connection {
 	private _senders : Map<number, MessageSender>;
 
 	private _id : number;
+	private _token : string;
 
 	private _ws : WebSocket;
 	private _wrtc : RTCPeerConnection;
@@ -42,6 +43,7 @@ class Connection {
 	setup() : void {
 		this.addHandler(initType, (msg : any) => {
 			this._id = msg.Client.Id;
+			this._token = msg.Token;
 			LogUtil.d("Initialized connection with id " + this._id);
 		});
 		this.addHandler(answerType, (msg : any) => { this.setRemoteDescription(msg); });
@@ -50,6 +52,8 @@ class Connection {
 
 	hasId() : boolean { return Util.defined(this._id) && this._id >= 0; }
 	id() : number { return this.hasId() ? this._id : -1; }
+	hasToken() : boolean { return Util.defined(this._token) && this._token.length > 0; }
+	token() : string { return this.hasToken() ? this._token : ""; }
 	wsConnecting() : boolean { return Util.defined(this._ws) && (this._ws.readyState === 0 || this._ws.readyState === 1); }
 	wsReady() : boolean { return Util.defined(this._ws) && this._ws.readyState === 1; }
 	dcConnecting() : boolean { return Util.defined(this._dc) && (this._dc.readyState === "connecting" || this._dc.readyState === "open"); }
@@ -174,7 +178,7 @@ class Connection {
 			if (Util.defined(this._dc)) {
 				this._dc.close();
 			}
-			ui.disconnected();
+			ui.disconnected(event.reason);
 		};
 	}
 
@@ -32,6 +32,7 @@ declare var initVoiceType : number;
 declare var joinVoiceType : number;
 declare var leftVoiceType : number;
 declare var chatType : number;
+declare var systemChatId : number;
 declare var keyType : number;
 
 declare var gameStateType : number;
@@ -39,6 +40,11 @@ declare var objectDataType : number;
 declare var objectUpdateType : number;
 declare var playerInitType : number;
 declare var levelInitType : number;
+declare var spectateType : number;
+declare var ackType : number;
+declare var compactObjectDataType : number;
+declare var shutdownType : number;
+declare var serverPingType : number;
 
 declare var lobbyGameState : number;
 declare var activeGameState : number;
@@ -64,6 +70,7 @@ declare var pickupSpace : number;
 declare var portalSpace : number;
 declare var goalSpace : number;
 declare var spawnSpace : number;
+declare var teamFlagSpace : number;
 
 declare var attributesProp : number;
 declare var byteAttributesProp : number;
@@ -86,6 +93,12 @@ declare var stateProp : number;
 declare var scoreProp : number;
 declare var vipProp : number;
 declare var teamsProp : number;
+declare var modeProp : number;
+declare var timerProp : number;
+declare var limitProp : number;
+declare var botProp : number;
+declare var inputProp : number;
+declare var scoreboardProp : number;
 
 declare var deletedAttribute : number;
 declare var attachedAttribute : number;
@@ -141,6 +154,7 @@ declare var rechargingPartState : number;
 declare var platformWall : number;
 declare var stairWall : number;
 declare var tableWallSubtype : number;
+declare var jumpPadWallSubtype : number;
 
 declare var archBlock : number;
 
@@ -1,5 +1,6 @@
 import * as THREE from 'three';
 
+import { Codec } from './codec.js'
 import { connection } from './connection.js'
 import { GameState } from './game_state.js'
 import { Keys } from './keys.js'
@@ -34,6 +35,8 @@ class Game {
 	private _sceneMap : SceneMap;
 	private _keys : Keys;
 	private _keySeqNum : number;
+	private _ackedKeySeqNum : number;
+	private _lastKeyTime : number;
 	private _lastSeqNum : number;
 	private _lastStateUpdate : number;
 
@@ -51,6 +54,8 @@ class Game {
 		this._sceneMap = new SceneMap();
 		this._keys = new Keys();
 		this._keySeqNum = 0;
+		this._ackedKeySeqNum = 0;
+		this._lastKeyTime = 0;
 		this._lastSeqNum = 0;
 		this._lastStateUpdate = Date.now();
 
@@ -69,9 +74,18 @@ class Game {
 
 		connection.addHandler(gameStateType, (msg : { [k: string]: any }) => { this.updateGameState(msg); });
 		connection.addHandler(objectDataType, (msg : { [k: string]: any }) => { this.update(msg); });
+		connection.addHandler(compactObjectDataType, (msg : { [k: string]: any }) => {
+			this.update({
+				T: objectDataType,
+				S: msg.S,
+				B: msg.B,
+				Os: Codec.decodeObjectPropMap(msg.Os),
+			});
+		});
 		connection.addHandler(objectUpdateType, (msg : { [k: string]: any }) => { this.update(msg); });
 		connection.addHandler(playerInitType, (msg : { [k: string]: any }) => { this.initPlayer(msg); });
 		connection.addHandler(levelInitType, (msg : { [k: string]: any }) => { this.initLevel(msg); });
+		connection.addHandler(spectateType, (msg : { [k: string]: any }) => { this.spectate(msg); });
 	}
 
 	hasId() : boolean { return this._id >= 0; }
@@ -94,6 +108,9 @@ class Game {
 	setTimeOfDay(timeOfDay : number) : void { this._timeOfDay = timeOfDay; }
 	startRender() : void { this.animate(); }
 
+	// Inputs sent that the server hasn't applied yet
+	unackedInputs() : number { return this._keySeqNum - this._ackedKeySeqNum; }
+
 	flushAdded() : number {
 		const copy = this._numObjectsAdded;
 		this._numObjectsAdded = 0;
@@ -137,6 +154,11 @@ class Game {
 		LogUtil.d("Initializing player with id " + this._id);
 	}
 
+	private spectate(msg : { [k: string]: any }) : void {
+		this.setInputMode(GameInputMode.SPECTATOR);
+		renderer.cameraController().follow(playerSpace, msg.Id);
+	}
+
 	private update(msg : { [k: string]: any }) : void {
 		const seqNum = msg.S;
 		if (msg.T === objectDataType) {
@@ -146,6 +168,14 @@ class Game {
 				this._lastStateUpdate = Date.now();
 				this._lastSeqNum = seqNum;
 			}
+
+			// Server only sends what changed since the last ack
+			connection.sendData({
+				T: ackType,
+				Ack: {
+					S: seqNum,
+				},
+			});
 		}
 
 		if (Util.defined(msg.Os)) {
@@ -178,6 +208,10 @@ class Game {
 
 				this.sceneMap().setData(space, id, object, seqNum);
 				this._numUpdates++;
+
+				if (space === playerSpace && id === this.id() && object.hasOwnProperty(inputProp)) {
+					this._ackedKeySeqNum = Math.max(this._ackedKeySeqNum, object[inputProp]);
+				}
 			}
 		}
 	}
@@ -304,6 +338,13 @@ class Game {
 			return;
 		}
 
+		// Server applies one input per frame, so don't send more often than that
+		const now = Date.now();
+		if (now - this._lastKeyTime < frameMillis) {
+			return;
+		}
+		this._lastKeyTime = Math.max(this._lastKeyTime + frameMillis, now - frameMillis);
+
 		this._keySeqNum++;
 		const keyMsg = this._keys.keyMsg(this._keySeqNum);
 		connection.sendData(keyMsg);
@@ -90,6 +90,9 @@ export class GameState {
 		if (this._state === victoryGameState) {
 			// TODO: put this variable in the game state message
 			game.setUpdateSpeed(0.3);
+			if (gameState.hasOwnProperty(scoreboardProp)) {
+				ui.showMatchStats(gameState[scoreboardProp]);
+			}
 			ui.announce({
 				type: AnnouncementType.SCORE,
 				ttl: 3000,
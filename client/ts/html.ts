@@ -89,6 +89,10 @@ export namespace Html {
 		elm.classList.add("bold");
 	}
 
+	export function italic(elm : HTMLElement) : void {
+		elm.classList.add("italic");
+	}
+
 	export function unselectable(elm : HTMLElement) : void {
 		elm.classList.add("no-select");
 	}
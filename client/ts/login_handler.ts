@@ -47,9 +47,18 @@ export class LoginHandler implements InterfaceHandler {
 				return;
 			}
 
-			let vars = new Map([["room", room], ["name", name]]);
-			if (connection.hasId()) {
+			let vars = new Map([["room", room], ["name", name], ["codec", "compact"]]);
+			if (connection.hasId() && connection.hasToken()) {
 				vars.set("id", "" + connection.id());
+				vars.set("token", connection.token());
+			}
+
+			// Spectating, replays and room settings are only supported through the page URL for now
+			const params = new URLSearchParams(window.location.search);
+			for (const param of ["spectate", "replay", "password", "max", "private"]) {
+				if (params.has(param)) {
+					vars.set(param, params.get(param));
+				}
 			}
 
 			connection.connect(vars, () => {
@@ -25,6 +25,16 @@ export class Pinger {
 			this._ping = Math.ceil(this._ping / this._pings.length);
 		});
 
+		// The server measures the ping it uses for lag compensation from these
+		connection.addHandler(serverPingType, (msg : any) => {
+			connection.sendData({
+				T: serverPingType,
+				Ping : {
+					S: msg.S,
+				}
+			});
+		});
+
 		connection.addSender(pingType, () => {
 			if (connection.ready()) {
 				connection.sendData({
@@ -252,10 +252,14 @@ export class RenderObject extends RenderMesh {
 	hasName() : boolean { return this._msg.has(nameProp); }
 	name() : string {
 		if (this.hasName()) {
+			if (this.isBot()) {
+				return this._msg.get(nameProp) + " [bot]";
+			}
 			return this._msg.get(nameProp);
 		}
 		return "";
 	}
+	isBot() : boolean { return this._msg.has(botProp) && this._msg.get(botProp); }
 
 	hasPos() : boolean { return this._msg.has(posProp); }
 	pos() : THREE.Vector2 {
@@ -518,11 +518,16 @@ export class RenderPlayer extends RenderAnimatedObject {
 				text: "right team",
 				color: Util.colorString(rightTeamColor),
 			}
-		default:
+		case 0:
 			return {
 				text: "neutral team",
 				color: Util.colorString(neutralTeamColor),
 			}
+		default:
+			return {
+				text: "team " + this.byteAttribute(teamByteAttribute),
+				color: Util.colorString(this.intAttribute(colorIntAttribute)),
+			}
 		}
 	}
 }
\ No newline at end of file
@@ -166,7 +166,7 @@ export class SceneMap {
 			renderObj = new RenderGrapplingHook(space, id);
 		} else if (space === pickupSpace) {
 			renderObj = new RenderPickup(space, id);
-		} else if (space === portalSpace || space === goalSpace) {
+		} else if (space === portalSpace || space === goalSpace || space === teamFlagSpace) {
 			renderObj = new RenderPortal(space, id);
 		} else if (space === spawnSpace) {
 			renderObj = new RenderSpawn(space, id);
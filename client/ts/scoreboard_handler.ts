@@ -4,14 +4,19 @@ import { InterfaceHandler } from './interface_handler.js'
 import { options } from './options.js'
 import { ScoreWrapper } from './score_wrapper.js'
 import { ui, InputMode } from './ui.js'
+import { Util } from './util.js'
 
 export class ScoreboardHandler implements InterfaceHandler {
+	private readonly _matchStatsMillis = 3000;
 
 	private _scoreboardElm : HTMLElement;
+	private _matchStatsElm : HTMLElement;
 	private _scores : Map<number, ScoreWrapper>;
+	private _timeoutId : number;
 
 	constructor() {
 		this._scoreboardElm = Html.elm(Html.divScoreboard);
+		this._matchStatsElm = Html.div();
 		this._scores = new Map<number, ScoreWrapper>();
 	}
 
@@ -22,6 +27,7 @@ export class ScoreboardHandler implements InterfaceHandler {
 			}
 
 			this.updateScoreboard();
+			this.hideMatchStats();
 			Html.displayBlock(this._scoreboardElm);
 			e.preventDefault();
 		});
@@ -40,6 +46,55 @@ export class ScoreboardHandler implements InterfaceHandler {
 		this._scores.forEach((score, id) => {
 			this._scoreboardElm.removeChild(score.elm());
 		});
+		this._scores.clear();
+		this._matchStatsElm.textContent = "";
+	}
+
+	// Shows everyone's stats for the match so far, sent by the server when a round ends
+	showMatchStats(stats : { [k: string]: any }) : void {
+		if (!this._scoreboardElm.contains(this._matchStatsElm)) {
+			this._scoreboardElm.prepend(this._matchStatsElm);
+		}
+		this._matchStatsElm.textContent = "";
+
+		const ids = Object.keys(stats).sort((a, b) => stats[b].Kills - stats[a].Kills);
+		for (const id of ids) {
+			const player = stats[id];
+			let damage = 0;
+			for (const weapon in player.Damage) {
+				damage += player.Damage[weapon];
+			}
+
+			const row = Html.div();
+			row.textContent = player.Name + "| " + player.Kills + "/" + player.Deaths + "/" + player.Assists
+				+ ", " + damage + " dmg, " + player.Wins + " wins"
+				+ (player.Escorts > 0 ? ", " + player.Escorts + " escorts" : "");
+			this._matchStatsElm.append(row);
+		}
+
+		this._scores.forEach((score) => {
+			Html.displayNone(score.elm());
+		});
+		Html.displayBlock(this._matchStatsElm);
+		Html.displayBlock(this._scoreboardElm);
+
+		if (Util.defined(this._timeoutId)) {
+			window.clearTimeout(this._timeoutId);
+		}
+		this._timeoutId = window.setTimeout(() => {
+			Html.displayNone(this._scoreboardElm);
+			this.hideMatchStats();
+		}, this._matchStatsMillis);
+	}
+
+	private hideMatchStats() : void {
+		if (Util.defined(this._timeoutId)) {
+			window.clearTimeout(this._timeoutId);
+		}
+		Html.displayNone(this._matchStatsElm);
+		this._scores.forEach((score) => {
+			Html.displayBlock(score.elm());
+		});
 	}
 
 	changeInputMode(mode : InputMode) : void {}
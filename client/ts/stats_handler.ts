@@ -36,6 +36,7 @@ export class StatsHandler implements InterfaceHandler {
 			text += " | Added/s " + Math.round(game.flushAdded() / this._intervalSec);
 			text += " | Extrapolated/s " + Math.round(game.flushExtrapolated() / this._intervalSec);
 			text += " | Updates/s: " + Math.round(game.flushUpdated() / this._intervalSec);
+			text += " | Unacked inputs: " + game.unackedInputs();
 			text += " | SetData/s: " + wasmGetStats();
 			text += " | Geometries: " + renderer.info().memory.geometries;
 			text += " | Textures: " + renderer.info().memory.textures;
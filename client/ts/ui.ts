@@ -121,10 +121,15 @@ class UI {
 
 	announce(announcement : Announcement) { this._announcementHandler.announce(announcement); }
 	tooltip(tooltip : Tooltip) { this._tooltipHandler.tooltip(tooltip); }
-	disconnected() : void {
+	showMatchStats(stats : { [k: string]: any }) { this._scoreboardHandler.showMatchStats(stats); }
+	disconnected(reason? : string) : void {
 		game.setInputMode(GameInputMode.PAUSE);
 		this.changeInputMode(InputMode.LOGIN);
-		this.print("Error: disconnected from server.")
+		if (Util.defined(reason) && reason.length > 0) {
+			this.print("Error: disconnected from server (" + reason + ").")
+		} else {
+			this.print("Error: disconnected from server.")
+		}
 	}
 	print(message : string) : void {
 		this._chatHandler.print(message);
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"time"
+)
+
+// Simulation time for a game. The server advances it by a fixed step every tick so that
+// the same inputs always produce the same state. WASM follows the wall clock for smooth rendering.
+type Clock struct {
+	now time.Time
+}
+
+func NewClock() *Clock {
+	return &Clock {
+		now: time.Unix(0, 0),
+	}
+}
+
+func (c Clock) Now() time.Time {
+	return c.now
+}
+
+func (c *Clock) Advance(step time.Duration) time.Time {
+	if isWasm {
+		c.now = time.Now()
+	} else {
+		c.now = c.now.Add(step)
+	}
+	return c.now
+}
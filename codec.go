@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+type CodecType uint8
+const (
+	unknownCodec CodecType = iota
+	packCodec
+	compactCodec
+)
+
+var codecNames = map[string]CodecType {
+	"pack": packCodec,
+	"compact": compactCodec,
+}
+
+// Width of a quantized Vec2, stored in the low bits of its header byte
+const (
+	packedVec2 uint8 = 0
+	int16Vec2 uint8 = 2
+	int32Vec2 uint8 = 4
+)
+
+// Fractional bits for quantized Vec2 props, stored in the high bits of the header byte
+// so clients don't need to know the precision ahead of time.
+var codecPrecision = map[Prop]uint8 {
+	dimProp: 8,
+	posProp: 8,
+	velProp: 8,
+	accProp: 6,
+	jerkProp: 6,
+	dirProp: 12,
+}
+
+// Object data with the props encoded by the compact codec
+type CompactObjectStateMsg struct {
+	T MessageType
+	S SeqNumType
+	B SeqNumType
+	Os []byte
+}
+
+// Encodes the object prop map as
+//   uvarint #spaces, then for each space: space, uvarint #objects,
+//   then for each object: uvarint id, uvarint prop bitmask, then the value of each prop in order.
+// Vec2 values are a header byte followed by fixed point little endian X and Y, everything else
+// is a uvarint length followed by the packed value. Props must fit in the 64 bit mask.
+func NewCompactObjectStateMsg(msg ObjectStateMsg) CompactObjectStateMsg {
+	return CompactObjectStateMsg {
+		T: compactObjectDataType,
+		S: msg.S,
+		B: msg.B,
+		Os: EncodeObjectPropMap(msg.Os),
+	}
+}
+
+func EncodeObjectPropMap(objects ObjectPropMap) []byte {
+	b := make([]byte, 0, 256)
+
+	spaces := make([]SpaceType, 0, len(objects))
+	for space := range(objects) {
+		spaces = append(spaces, space)
+	}
+	sort.Slice(spaces, func(i, j int) bool { return spaces[i] < spaces[j] })
+
+	b = appendUvarint(b, uint64(len(spaces)))
+	for _, space := range(spaces) {
+		spacedObjects := objects[space]
+		b = append(b, byte(space))
+		b = appendUvarint(b, uint64(len(spacedObjects)))
+
+		ids := make([]IdType, 0, len(spacedObjects))
+		for id := range(spacedObjects) {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		for _, id := range(ids) {
+			b = appendUvarint(b, uint64(id))
+			b = encodePropMap(b, spacedObjects[id])
+		}
+	}
+	return b
+}
+
+func encodePropMap(b []byte, props PropMap) []byte {
+	mask := uint64(0)
+	for prop := range(props) {
+		if prop >= 64 {
+			panic(fmt.Sprintf("prop %d doesn't fit in the compact codec's mask", prop))
+		}
+		mask |= 1 << prop
+	}
+	b = appendUvarint(b, mask)
+
+	for prop := Prop(0); prop < 64; prop += 1 {
+		if mask & (1 << prop) == 0 {
+			continue
+		}
+
+		value := props[prop]
+		if vec, ok := value.(Vec2); ok {
+			if bits, ok := codecPrecision[prop]; ok {
+				b = encodeVec2(b, vec, bits)
+				continue
+			}
+		}
+
+		packed := Pack(value)
+		if _, ok := codecPrecision[prop]; ok {
+			b = append(b, packedVec2)
+		}
+		b = appendUvarint(b, uint64(len(packed)))
+		b = append(b, packed...)
+	}
+	return b
+}
+
+func encodeVec2(b []byte, vec Vec2, bits uint8) []byte {
+	scale := float64(uint32(1) << bits)
+	x := math.Round(vec.X * scale)
+	y := math.Round(vec.Y * scale)
+
+	if fitsInt16(x) && fitsInt16(y) {
+		b = append(b, bits << 4 | int16Vec2)
+		b = appendUint16(b, uint16(int16(x)))
+		b = appendUint16(b, uint16(int16(y)))
+	} else if fitsInt32(x) && fitsInt32(y) {
+		b = append(b, bits << 4 | int32Vec2)
+		b = appendUint32(b, uint32(int32(x)))
+		b = appendUint32(b, uint32(int32(y)))
+	} else {
+		packed := Pack(vec)
+		b = append(b, packedVec2)
+		b = appendUvarint(b, uint64(len(packed)))
+		b = append(b, packed...)
+	}
+	return b
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func fitsInt16(v float64) bool {
+	return v >= math.MinInt16 && v <= math.MaxInt16
+}
+
+func fitsInt32(v float64) bool {
+	return v >= math.MinInt32 && v <= math.MaxInt32
+}
+
+// Compares the size of object data sent with the compact codec against Pack.
+type CodecStats struct {
+	msgs int
+	packedBytes int
+	compactBytes int
+}
+
+func NewCodecStats() CodecStats {
+	return CodecStats {}
+}
+
+func (cs *CodecStats) Add(msg ObjectStateMsg, compact []byte) {
+	cs.msgs += 1
+	cs.packedBytes += len(Pack(&msg))
+	cs.compactBytes += len(compact)
+}
+
+func (cs CodecStats) Empty() bool {
+	return cs.msgs == 0
+}
+
+func (cs *CodecStats) Reset() {
+	cs.msgs = 0
+	cs.packedBytes = 0
+	cs.compactBytes = 0
+}
+
+func (cs CodecStats) String() string {
+	if cs.msgs == 0 {
+		return "no object data sampled"
+	}
+	return fmt.Sprintf("object data avg %d bytes compact vs %d bytes packed (%.0f%%) over %d msgs",
+		cs.compactBytes / cs.msgs, cs.packedBytes / cs.msgs,
+		100 * float64(cs.compactBytes) / float64(cs.packedBytes), cs.msgs)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+// Mirrors the client's decoder in codec.ts. Packed values are returned still packed.
+type codecReader struct {
+	r *bytes.Reader
+}
+
+func (cr codecReader) byte(t *testing.T) byte {
+	b, err := cr.r.ReadByte()
+	if err != nil {
+		t.Fatalf("unexpected end of data: %v", err)
+	}
+	return b
+}
+
+func (cr codecReader) uvarint(t *testing.T) uint64 {
+	v, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		t.Fatalf("bad uvarint: %v", err)
+	}
+	return v
+}
+
+func (cr codecReader) bytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(cr.r, b); err != nil {
+		t.Fatalf("unexpected end of data: %v", err)
+	}
+	return b
+}
+
+func (cr codecReader) packed(t *testing.T) []byte {
+	return cr.bytes(t, int(cr.uvarint(t)))
+}
+
+func decodeObjectPropMap(t *testing.T, b []byte) map[SpaceType]map[IdType]map[Prop]interface{} {
+	cr := codecReader { r: bytes.NewReader(b) }
+	objects := make(map[SpaceType]map[IdType]map[Prop]interface{})
+
+	numSpaces := cr.uvarint(t)
+	for i := uint64(0); i < numSpaces; i += 1 {
+		space := SpaceType(cr.byte(t))
+		objects[space] = make(map[IdType]map[Prop]interface{})
+
+		numObjects := cr.uvarint(t)
+		for j := uint64(0); j < numObjects; j += 1 {
+			id := IdType(cr.uvarint(t))
+			objects[space][id] = decodePropMap(t, cr)
+		}
+	}
+
+	if cr.r.Len() > 0 {
+		t.Fatalf("%d bytes left over after decoding", cr.r.Len())
+	}
+	return objects
+}
+
+func decodePropMap(t *testing.T, cr codecReader) map[Prop]interface{} {
+	props := make(map[Prop]interface{})
+	mask := cr.uvarint(t)
+	for prop := Prop(0); prop < 64; prop += 1 {
+		if mask & (1 << prop) == 0 {
+			continue
+		}
+
+		if _, ok := codecPrecision[prop]; ok {
+			props[prop] = decodeVec2(t, cr)
+		} else {
+			props[prop] = cr.packed(t)
+		}
+	}
+	return props
+}
+
+func decodeVec2(t *testing.T, cr codecReader) interface{} {
+	header := cr.byte(t)
+	scale := float64(uint32(1) << (header >> 4))
+
+	switch (header & 0xf) {
+	case int16Vec2:
+		b := cr.bytes(t, 4)
+		x := int16(binary.LittleEndian.Uint16(b[0:2]))
+		y := int16(binary.LittleEndian.Uint16(b[2:4]))
+		return NewVec2(float64(x) / scale, float64(y) / scale)
+	case int32Vec2:
+		b := cr.bytes(t, 8)
+		x := int32(binary.LittleEndian.Uint32(b[0:4]))
+		y := int32(binary.LittleEndian.Uint32(b[4:8]))
+		return NewVec2(float64(x) / scale, float64(y) / scale)
+	}
+	return cr.packed(t)
+}
+
+func checkRoundTrip(t *testing.T, objects ObjectPropMap) {
+	decoded := decodeObjectPropMap(t, EncodeObjectPropMap(objects))
+
+	if len(decoded) != len(objects) {
+		t.Fatalf("decoded %d spaces, expected %d", len(decoded), len(objects))
+	}
+	for space, spacedObjects := range(objects) {
+		if len(decoded[space]) != len(spacedObjects) {
+			t.Fatalf("decoded %d objects in space %d, expected %d", len(decoded[space]), space, len(spacedObjects))
+		}
+		for id, props := range(spacedObjects) {
+			decodedProps := decoded[space][id]
+			if len(decodedProps) != len(props) {
+				t.Fatalf("decoded %d props for %d:%d, expected %d", len(decodedProps), space, id, len(props))
+			}
+			for prop, value := range(props) {
+				checkProp(t, prop, value, decodedProps[prop])
+			}
+		}
+	}
+}
+
+func checkProp(t *testing.T, prop Prop, value interface{}, decoded interface{}) {
+	vec, isVec := value.(Vec2)
+	decodedVec, decodedIsVec := decoded.(Vec2)
+
+	if bits, ok := codecPrecision[prop]; ok && isVec && decodedIsVec {
+		tolerance := 0.5 / float64(uint32(1) << bits)
+		if math.Abs(vec.X - decodedVec.X) > tolerance || math.Abs(vec.Y - decodedVec.Y) > tolerance {
+			t.Errorf("prop %d decoded as %v, expected %v", prop, decodedVec, vec)
+		}
+		return
+	}
+
+	packed, ok := decoded.([]byte)
+	if !ok || !bytes.Equal(packed, Pack(value)) {
+		t.Errorf("prop %d decoded as %v, expected %v packed", prop, decoded, value)
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	checkRoundTrip(t, ObjectPropMap {
+		playerSpace: SpacedPropMap {
+			0: PropMap {
+				posProp: NewVec2(12.5, -3.25),
+				velProp: NewVec2(0, 0),
+				dirProp: NewVec2(0.70710678, 0.70710678),
+				nameProp: "dude",
+				keysProp: map[KeyType]bool { upKey: true },
+			},
+			300: PropMap {
+				attributesProp: map[AttributeType]bool { autoRespawnAttribute: true },
+				intAttributesProp: map[IntAttributeType]int { killIntAttribute: 3 },
+			},
+		},
+		wallSpace: SpacedPropMap {
+			7: PropMap {
+				dimProp: NewVec2(20, 1),
+			},
+		},
+	})
+}
+
+func TestCodecRoundTripEmpty(t *testing.T) {
+	checkRoundTrip(t, ObjectPropMap {})
+	checkRoundTrip(t, ObjectPropMap {
+		playerSpace: SpacedPropMap {
+			1: PropMap {},
+		},
+	})
+}
+
+// Values too big for 16 bits fall back to 32 bits, and anything bigger is packed as is.
+func TestCodecRoundTripLargeVec2(t *testing.T) {
+	for _, vec := range([]Vec2 {
+		NewVec2(127, -128),
+		NewVec2(128, 0),
+		NewVec2(-5000.125, 4000),
+		NewVec2(1e7, 0),
+		NewVec2(math.Inf(1), 0),
+	}) {
+		checkRoundTrip(t, ObjectPropMap {
+			playerSpace: SpacedPropMap {
+				1: PropMap { posProp: vec },
+			},
+		})
+	}
+}
+
+// Vec2 props that are sent as something else are packed, with a header so the client can tell.
+func TestCodecRoundTripNonVec2(t *testing.T) {
+	checkRoundTrip(t, ObjectPropMap {
+		playerSpace: SpacedPropMap {
+			1: PropMap { posProp: "not a vector" },
+		},
+	})
+}
+
+// Every prop bit up to the last one in the 64 bit mask has to survive the uvarint.
+func TestCodecRoundTripAllProps(t *testing.T) {
+	props := make(PropMap)
+	for prop := Prop(0); prop < 64; prop += 1 {
+		if _, ok := codecPrecision[prop]; ok {
+			props[prop] = NewVec2(float64(prop), -float64(prop))
+		} else {
+			props[prop] = int(prop)
+		}
+	}
+
+	checkRoundTrip(t, ObjectPropMap {
+		playerSpace: SpacedPropMap {
+			1: props,
+		},
+	})
+}
+
+// Props past the mask can't be sent, so they shouldn't be dropped silently.
+func TestCodecPropPastMask(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("encoding prop 64 should panic instead of dropping it")
+		}
+	}()
+	EncodeObjectPropMap(ObjectPropMap {
+		playerSpace: SpacedPropMap {
+			1: PropMap { Prop(64): 1 },
+		},
+	})
+}
+
+func newCodecBenchmarkGame() *Game {
+	game := NewGame()
+	game.LoadLevel(birdTownLevel, 1)
+	for id := IdType(0); id < 8; id += 1 {
+		game.AddBot(id, "bot")
+	}
+	for i := 0; i < 120; i += 1 {
+		game.Update()
+	}
+	game.updateSnapshot()
+	return game
+}
+
+// Run with -bench Codec to compare the bytes per message of each codec.
+func BenchmarkCodecPackObjectData(b *testing.B) {
+	msg := newCodecBenchmarkGame().createObjectDataMsg(0)
+	b.ResetTimer()
+
+	size := 0
+	for i := 0; i < b.N; i += 1 {
+		size = len(Pack(&msg))
+	}
+	b.ReportMetric(float64(size), "bytes/msg")
+}
+
+func BenchmarkCodecCompactObjectData(b *testing.B) {
+	msg := newCodecBenchmarkGame().createObjectDataMsg(0)
+	b.ResetTimer()
+
+	size := 0
+	for i := 0; i < b.N; i += 1 {
+		compact := NewCompactObjectStateMsg(msg)
+		size = len(Pack(&compact))
+	}
+	b.ReportMetric(float64(size), "bytes/msg")
+}
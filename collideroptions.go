@@ -56,10 +56,6 @@ func (co ColliderOptions) Evaluate(object Object) bool {
 		return false
 	}
 
-	if len(co.attributes) == 0 {
-		return true
-	}
-
 	for attribute, include := range(co.attributes) {
 		if !include && object.HasAttribute(attribute) {
 			return false
@@ -5,6 +5,9 @@ const (
 	leftTeamColor int = 0xff0000
 	rightTeamColor int = 0x0000ff
 	vipColor int = 0xffff00
+	deathmatchModeColor int = 0xff7f00
+	ctfModeColor int = 0x7fff00
+	ffaModeColor int = 0xff00ff
 )
 
 const (
@@ -21,6 +24,8 @@ const (
 	chargedBoltColor int = 0x10b3ff
 	rocketExplosionColor int = 0xbb4444
 	tableColor int = 0x996312
+	crateColor int = 0x7a5230
+	jumpPadColor int = 0x0ffc89
 
 	starRed = 0xed0505
 	starBlue = 0x020f9e
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxCommandBots int = 16
+)
+
+type Command struct {
+	usage string
+	description string
+	minArgs int
+	ownerOnly bool
+
+	// Returns the reply to send back to the client
+	run func(r *Room, c *Client, args []string) (string, error)
+}
+
+var commands map[string]Command
+
+func init() {
+	commands = map[string]Command {
+		"help": {
+			usage: "/help",
+			description: "list commands",
+			run: runHelpCommand,
+		},
+		"team": {
+			usage: "/team <team> [id]",
+			description: "switch teams in the lobby, owner can switch others",
+			minArgs: 1,
+			run: runTeamCommand,
+		},
+		"kick": {
+			usage: "/kick <id>",
+			description: "disconnect a player",
+			minArgs: 1,
+			ownerOnly: true,
+			run: runKickCommand,
+		},
+		"mode": {
+			usage: "/mode <" + strings.Join(gameModeNames(), "|") + "> [" + strings.Join(modeOptionNames(), "|") + "=<value>]...",
+			description: "change the game mode and its settings in the lobby, settings last until changed",
+			minArgs: 1,
+			ownerOnly: true,
+			run: runModeCommand,
+		},
+		"restart": {
+			usage: "/restart",
+			description: "go back to the lobby",
+			ownerOnly: true,
+			run: runRestartCommand,
+		},
+		"seed": {
+			usage: "/seed <seed>",
+			description: "use the seed for the next level",
+			minArgs: 1,
+			ownerOnly: true,
+			run: runSeedCommand,
+		},
+		"level": {
+			// Level names aren't loaded yet, so they're listed when the command fails instead
+			usage: "/level <name>",
+			description: "change the level played after the lobby",
+			minArgs: 1,
+			ownerOnly: true,
+			run: runLevelCommand,
+		},
+		"bots": {
+			usage: "/bots <count>",
+			description: "set the number of bots",
+			minArgs: 1,
+			ownerOnly: true,
+			run: runBotsCommand,
+		},
+		"mute": {
+			usage: "/mute <id>",
+			description: "hide someone's chat messages from the room",
+			minArgs: 1,
+			ownerOnly: true,
+			run: runMuteCommand,
+		},
+		"unmute": {
+			usage: "/unmute <id>",
+			description: "let a muted player chat again",
+			minArgs: 1,
+			ownerOnly: true,
+			run: runUnmuteCommand,
+		},
+		"owner": {
+			usage: "/owner <id>",
+			description: "give someone else the owner role",
+			minArgs: 1,
+			ownerOnly: true,
+			run: runOwnerCommand,
+		},
+	}
+}
+
+func IsCommand(message string) bool {
+	return strings.HasPrefix(strings.TrimSpace(message), "/")
+}
+
+// Runs the command and returns the system message to reply with.
+func (r *Room) processCommand(c *Client, message string) ChatMsg {
+	fields := strings.Fields(strings.TrimSpace(message))
+	name := strings.ToLower(strings.TrimPrefix(fields[0], "/"))
+	args := fields[1:]
+
+	reply := func(m string) ChatMsg {
+		return NewSystemChatMsg(m)
+	}
+
+	command, ok := commands[name]
+	if !ok {
+		return reply(fmt.Sprintf("Unknown command /%s, try /help", name))
+	}
+	if command.ownerOnly && !r.IsOwner(c) {
+		return reply(fmt.Sprintf("Only the room owner can use /%s", name))
+	}
+	if command.ownerOnly && r.playback != nil {
+		return reply(fmt.Sprintf("Can't use /%s while watching a replay", name))
+	}
+	if len(args) < command.minArgs {
+		return reply("Usage: " + command.usage)
+	}
+
+	response, err := command.run(r, c, args)
+	if err != nil {
+		return reply(fmt.Sprintf("/%s failed: %v", name, err))
+	}
+	r.print(fmt.Sprintf("%s ran %s", c.GetDisplayName(), strings.Join(fields, " ")))
+	return reply(response)
+}
+
+func NewSystemChatMsg(message string) ChatMsg {
+	return ChatMsg {
+		T: chatType,
+		Id: systemChatId,
+		M: message,
+	}
+}
+
+// Send a system message to everyone and keep it in the chat history.
+func (r *Room) announce(message string) {
+	msg := NewSystemChatMsg(message)
+	r.chat.addChatMsg(msg)
+	r.send(&msg)
+}
+
+func parseId(arg string) (IdType, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil || id < 0 || id >= int(systemChatId) {
+		return 0, fmt.Errorf("invalid id %s", arg)
+	}
+	return IdType(id), nil
+}
+
+func gameModeNames() []string {
+	names := make([]string, 0, len(gameModes))
+	for _, id := range(GameModeIds()) {
+		names = append(names, GameModeName(id))
+	}
+	return names
+}
+
+func runHelpCommand(r *Room, c *Client, args []string) (string, error) {
+	names := make([]string, 0, len(commands))
+	for name, command := range(commands) {
+		if command.ownerOnly && !r.IsOwner(c) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("Commands:")
+	for _, name := range(names) {
+		sb.WriteString(fmt.Sprintf(" %s (%s);", commands[name].usage, commands[name].description))
+	}
+	return strings.TrimSuffix(sb.String(), ";"), nil
+}
+
+func runTeamCommand(r *Room, c *Client, args []string) (string, error) {
+	numTeams := int(r.game.GetGrid().GetNumTeams())
+	if numTeams == 0 {
+		return "", fmt.Errorf("teams are assigned automatically in %s", GameModeName(r.game.GetGrid().GetGameModeId()))
+	}
+	team, err := strconv.Atoi(args[0])
+	if err != nil || team < 0 || team > numTeams {
+		return "", fmt.Errorf("team should be 0-%d", numTeams)
+	}
+
+	id := c.id
+	if len(args) > 1 {
+		if !r.IsOwner(c) {
+			return "", fmt.Errorf("only the room owner can switch other players")
+		}
+		id, err = parseId(args[1])
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if state, _ := r.game.GetGrid().GetGameState(); state != lobbyGameState {
+		return "", fmt.Errorf("teams can only be changed in the lobby")
+	}
+	if !r.game.SetTeam(id, uint8(team)) {
+		return "", fmt.Errorf("no player with id %d", id)
+	}
+	return fmt.Sprintf("Moved #%d to team %d", id, team), nil
+}
+
+func runKickCommand(r *Room, c *Client, args []string) (string, error) {
+	id, err := parseId(args[0])
+	if err != nil {
+		return "", err
+	}
+	if id == c.id {
+		return "", fmt.Errorf("can't kick yourself")
+	}
+	if r.game.HasBot(id) {
+		return "", fmt.Errorf("use /bots to remove bots")
+	}
+
+	client, ok := r.clients[id]
+	if !ok {
+		return "", fmt.Errorf("no client with id %d", id)
+	}
+
+	closeWithReason(client.ws, kickedCloseCode, "kicked by the room owner")
+	r.handleUnregister(client)
+	// Kicked players can come back, but only as someone new
+	r.identities.Revoke(id)
+	r.announce(fmt.Sprintf("%s was kicked", client.GetDisplayName()))
+	return fmt.Sprintf("Kicked %s", client.GetDisplayName()), nil
+}
+
+func runModeCommand(r *Room, c *Client, args []string) (string, error) {
+	mode, ok := GameModeByName(strings.ToLower(args[0]))
+	if !ok {
+		return "", fmt.Errorf("unknown mode %s, expected one of %s", args[0], strings.Join(gameModeNames(), ", "))
+	}
+
+	if state, _ := r.game.GetGrid().GetGameState(); state != lobbyGameState {
+		return "", fmt.Errorf("the mode can only be changed in the lobby")
+	}
+
+	// Check every option against a fresh mode before changing anything
+	options := make(map[string]int)
+	for _, arg := range(args[1:]) {
+		parts := strings.SplitN(arg, "=", 2)
+		option, ok := modeOptions[strings.ToLower(parts[0])]
+		if !ok || len(parts) != 2 {
+			return "", fmt.Errorf("unknown option %s, expected one of %s", arg, strings.Join(modeOptionNames(), ", "))
+		}
+		value, err := strconv.Atoi(parts[1])
+		if err != nil || value < option.min || value > option.max {
+			return "", fmt.Errorf("%s should be %d-%d", parts[0], option.min, option.max)
+		}
+		if !option.apply(NewGameMode(mode), value) {
+			return "", fmt.Errorf("%s doesn't have a %s setting", GameModeName(mode), parts[0])
+		}
+		options[strings.ToLower(parts[0])] = value
+	}
+
+	r.game.SetGameMode(mode, options)
+
+	settings := make([]string, 0, len(options))
+	for _, name := range(modeOptionNames()) {
+		if value, ok := options[name]; ok {
+			settings = append(settings, fmt.Sprintf("%s=%d", name, value))
+		}
+	}
+
+	description := GameModeName(mode)
+	if len(settings) > 0 {
+		description += " (" + strings.Join(settings, ", ") + ")"
+	}
+	r.announce(fmt.Sprintf("Game mode changed to %s", description))
+	return fmt.Sprintf("Changed mode to %s", description), nil
+}
+
+func runRestartCommand(r *Room, c *Client, args []string) (string, error) {
+	r.game.RestartGameMode()
+	r.announce("Restarting the game")
+	return "Restarted", nil
+}
+
+func runSeedCommand(r *Room, c *Client, args []string) (string, error) {
+	seed, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid seed %s", args[0])
+	}
+
+	r.game.QueueLevelSeed(LevelSeedType(seed))
+	return fmt.Sprintf("The next level will use seed %d", seed), nil
+}
+
+func runLevelCommand(r *Room, c *Client, args []string) (string, error) {
+	id, ok := LevelByName(args[0])
+	if !ok || id == lobbyLevel {
+		return "", fmt.Errorf("unknown level %s, expected one of %s", args[0], strings.Join(MatchLevelNames(), ", "))
+	}
+
+	if state, _ := r.game.GetGrid().GetGameState(); state != lobbyGameState {
+		return "", fmt.Errorf("the level can only be changed in the lobby")
+	}
+
+	r.game.SetMatchLevel(id)
+	r.announce(fmt.Sprintf("Level changed to %s", LevelName(id)))
+	return fmt.Sprintf("Changed level to %s", LevelName(id)), nil
+}
+
+func runBotsCommand(r *Room, c *Client, args []string) (string, error) {
+	count, err := strconv.Atoi(args[0])
+	if err != nil || count < 0 || count > maxCommandBots {
+		return "", fmt.Errorf("bot count should be 0-%d", maxCommandBots)
+	}
+
+	r.setBotCount(count)
+	return fmt.Sprintf("Now playing with %d bots", len(r.game.GetBotIds())), nil
+}
+
+func runOwnerCommand(r *Room, c *Client, args []string) (string, error) {
+	id, err := parseId(args[0])
+	if err != nil {
+		return "", err
+	}
+	client, ok := r.clients[id]
+	if !ok {
+		return "", fmt.Errorf("no client with id %d", id)
+	}
+
+	if client == c {
+		return "", fmt.Errorf("you are already the room owner")
+	}
+
+	r.setOwner(client)
+	return fmt.Sprintf("Gave the owner role to %s", client.GetDisplayName()), nil
+}
+
+func runMuteCommand(r *Room, c *Client, args []string) (string, error) {
+	id, err := parseId(args[0])
+	if err != nil {
+		return "", err
+	}
+	if id == c.id {
+		return "", fmt.Errorf("can't mute yourself")
+	}
+	client, ok := r.clients[id]
+	if !ok {
+		return "", fmt.Errorf("no client with id %d", id)
+	}
+	if r.chat.IsMuted(id) {
+		return "", fmt.Errorf("%s is already muted", client.GetDisplayName())
+	}
+
+	r.chat.Mute(id)
+	muteMsg := NewSystemChatMsg("You were muted by the room owner.")
+	client.Send(&muteMsg)
+	return fmt.Sprintf("Muted %s", client.GetDisplayName()), nil
+}
+
+func runUnmuteCommand(r *Room, c *Client, args []string) (string, error) {
+	id, err := parseId(args[0])
+	if err != nil {
+		return "", err
+	}
+	if !r.chat.IsMuted(id) {
+		return "", fmt.Errorf("#%d is not muted", id)
+	}
+
+	r.chat.Unmute(id)
+	if client, ok := r.clients[id]; ok {
+		unmuteMsg := NewSystemChatMsg("You can chat again.")
+		client.Send(&unmuteMsg)
+	}
+	return fmt.Sprintf("Unmuted #%d", id), nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	ctfMaxScore int = 3
+)
+
+type CtfMode struct {
+	BaseGameMode
+
+	maxScore int
+	flags map[uint8]*TeamFlag
+	goals []SpacedId
+	restartTimer Timer
+}
+
+func NewCtfMode() *CtfMode {
+	mode := &CtfMode {
+		BaseGameMode: NewBaseGameMode(ctfGameMode),
+
+		maxScore: ctfMaxScore,
+		flags: make(map[uint8]*TeamFlag),
+		goals: make([]SpacedId, 0),
+		restartTimer: NewTimer(3 * time.Second),
+	}
+	return mode
+}
+
+func (cm *CtfMode) SetMaxScore(maxScore int) {
+	cm.maxScore = maxScore
+}
+
+func (cm *CtfMode) Update(g *Grid) {
+	cm.BaseGameMode.Update(g)
+
+	if cm.state == lobbyGameState {
+		if cm.firstFrame {
+			cm.resetPlayers(g)
+		}
+
+		players := g.GetObjects(playerSpace)
+		cm.updateTeams(players)
+
+		if len(cm.teams[0]) > 0 || len(cm.teams[1]) == 0 || len(cm.teams[2]) == 0 {
+			return
+		}
+
+		cm.players = make(map[SpacedId]Object)
+		for _, player := range(players) {
+			cm.players[player.GetSpacedId()] = player
+		}
+
+		cm.teamScores = make(map[uint8]int)
+		cm.teamScores[1], cm.teamScores[2] = 0, 0
+		cm.winningTeam = 0
+		cm.config = GameModeConfig {
+			leftTeam: 1,
+			rightTeam: 2,
+			reverse: false,
+			nextState: activeGameState,
+			levelId: birdTownLevel,
+		}
+		cm.SetState(setupGameState)
+	} else if cm.state == activeGameState {
+		if cm.firstFrame {
+			for _, player := range(cm.players) {
+				player.AddInternalAttribute(autoRespawnAttribute)
+				player.RemoveAttribute(carryingAttribute)
+				player.(*Player).SetSpawn(g)
+				player.Respawn()
+			}
+			cm.placeBases(g)
+		}
+
+		for sid, player := range(cm.players) {
+			if g.Get(sid) == nil || player.HasAttribute(deletedAttribute) {
+				delete(cm.players, sid)
+			}
+		}
+		cm.updateTeams(g.GetObjects(playerSpace))
+
+		if len(cm.teams[cm.config.leftTeam]) == 0 || len(cm.teams[cm.config.rightTeam]) == 0 {
+			cm.clearBases(g)
+			cm.config.levelId = lobbyLevel
+			cm.config.nextState = lobbyGameState
+			cm.SetState(setupGameState)
+			return
+		}
+
+		if cm.winningTeam != 0 {
+			cm.clearBases(g)
+			cm.SetState(victoryGameState)
+		}
+	} else if cm.state == victoryGameState {
+		if cm.firstFrame {
+			cm.restartTimer.Start(cm.now)
+			return
+		}
+		if cm.restartTimer.On(cm.now) {
+			return
+		}
+
+		cm.config.levelId = lobbyLevel
+		cm.config.nextState = lobbyGameState
+		cm.SetState(setupGameState)
+	}
+}
+
+// Called when a goal finishes charging, which means a flag carrier made it home.
+func (cm *CtfMode) SetWinningTeam(team uint8) {
+	if cm.state != activeGameState || team == 0 {
+		return
+	}
+
+	// Can only capture while your own flag is safe
+	if flag, ok := cm.flags[team]; ok && !flag.AtHome() {
+		return
+	}
+
+	for flagTeam, flag := range(cm.flags) {
+		if flagTeam == team || flag.GetCarrier().Invalid() || flag.Expired(cm.now) {
+			continue
+		}
+
+		cm.teamScores[team] += 1
+		flag.SetConstantTTL(0, cm.now)
+		if cm.teamScores[team] >= cm.maxScore {
+			cm.winningTeam = team
+		}
+	}
+}
+
+func (cm *CtfMode) Restart(g *Grid) {
+	cm.clearBases(g)
+	cm.BaseGameMode.Restart(g)
+}
+
+func (cm CtfMode) GetUpdates() Data {
+	data := cm.BaseGameMode.GetUpdates()
+	data.Set(limitProp, cm.maxScore)
+	return data
+}
+
+// Each team gets a flag and a capture goal at its spawn. Goals from the level are for VIP only.
+func (cm *CtfMode) placeBases(g *Grid) {
+	cm.clearBases(g)
+
+	for _, goal := range(g.GetObjects(goalSpace)) {
+		goal.(*Goal).SetTeam(0)
+	}
+
+	for _, spawn := range(g.GetObjects(spawnSpace)) {
+		team, ok := spawn.GetByteAttribute(teamByteAttribute)
+		if !ok || team == 0 {
+			continue
+		}
+		if _, ok := cm.flags[team]; ok {
+			continue
+		}
+
+		pos := spawn.Pos()
+		goal := NewGoal(NewInit(g.NextSpacedId(goalSpace), pos, NewVec2(spawn.Dim().X, 4)))
+		goal.SetTeam(team)
+		g.Upsert(goal)
+		cm.goals = append(cm.goals, goal.GetSpacedId())
+
+		flag := NewTeamFlag(NewInit(g.NextSpacedId(teamFlagSpace), pos, NewVec2(0.6, 1.6)))
+		flag.SetTeam(team)
+		g.Upsert(flag)
+		cm.flags[team] = flag
+	}
+}
+
+func (cm *CtfMode) clearBases(g *Grid) {
+	for _, flag := range(cm.flags) {
+		g.Delete(flag.GetSpacedId())
+	}
+	for _, goal := range(cm.goals) {
+		g.Delete(goal)
+	}
+
+	cm.flags = make(map[uint8]*TeamFlag)
+	cm.goals = make([]SpacedId, 0)
+}
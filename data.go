@@ -29,6 +29,12 @@ const (
 	scoreProp
 	vipProp
 	teamsProp
+	modeProp
+	timerProp
+	limitProp
+	botProp
+	inputProp
+	scoreboardProp
 )
 
 type PropMap map[Prop]interface{}
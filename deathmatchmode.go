@@ -0,0 +1,181 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	deathmatchKillLimit int = 20
+	deathmatchTimeLimit time.Duration = 5 * time.Minute
+)
+
+type DeathmatchMode struct {
+	BaseGameMode
+
+	killLimit int
+	roundTimer Timer
+	restartTimer Timer
+
+	// Kills that have already been added to the team scores
+	kills map[SpacedId]int
+}
+
+func NewDeathmatchMode() *DeathmatchMode {
+	mode := &DeathmatchMode {
+		BaseGameMode: NewBaseGameMode(deathmatchGameMode),
+
+		killLimit: deathmatchKillLimit,
+		roundTimer: NewTimer(deathmatchTimeLimit),
+		restartTimer: NewTimer(3 * time.Second),
+
+		kills: make(map[SpacedId]int),
+	}
+	return mode
+}
+
+func (dm *DeathmatchMode) SetKillLimit(killLimit int) {
+	dm.killLimit = killLimit
+}
+
+func (dm *DeathmatchMode) SetTimeLimit(timeLimit time.Duration) {
+	dm.roundTimer.SetDuration(timeLimit)
+}
+
+func (dm *DeathmatchMode) Update(g *Grid) {
+	dm.BaseGameMode.Update(g)
+
+	if dm.state == lobbyGameState {
+		if dm.firstFrame {
+			dm.resetPlayers(g)
+		}
+
+		players := g.GetObjects(playerSpace)
+		dm.updateTeams(players)
+
+		// Any split works as long as everyone picked a team and there is someone to fight
+		if len(dm.teams[0]) > 0 || len(dm.teams[1]) == 0 || len(dm.teams[2]) == 0 {
+			return
+		}
+
+		dm.players = make(map[SpacedId]Object)
+		for _, player := range(players) {
+			dm.players[player.GetSpacedId()] = player
+			player.SetIntAttribute(killIntAttribute, 0)
+			player.SetIntAttribute(deathIntAttribute, 0)
+		}
+
+		dm.teamScores = make(map[uint8]int)
+		dm.teamScores[1], dm.teamScores[2] = 0, 0
+		dm.kills = make(map[SpacedId]int)
+		dm.winningTeam = 0
+		dm.config = GameModeConfig {
+			leftTeam: 1,
+			rightTeam: 2,
+			reverse: false,
+			nextState: activeGameState,
+			levelId: birdTownLevel,
+		}
+		dm.SetState(setupGameState)
+	} else if dm.state == activeGameState {
+		if dm.firstFrame {
+			for _, player := range(dm.players) {
+				player.AddInternalAttribute(autoRespawnAttribute)
+				player.(*Player).SetSpawn(g)
+				player.Respawn()
+			}
+			dm.roundTimer.Start(dm.now)
+		}
+
+		dm.updatePlayers(g)
+		if len(dm.players) == 0 {
+			dm.config.levelId = lobbyLevel
+			dm.config.nextState = lobbyGameState
+			dm.SetState(setupGameState)
+			return
+		}
+
+		dm.updateScores()
+		if dm.teamScores[1] >= dm.killLimit || dm.teamScores[2] >= dm.killLimit || dm.roundTimer.Finished(dm.now) {
+			dm.winningTeam = dm.getLeadingTeam()
+			dm.roundTimer.Stop()
+			dm.SetState(victoryGameState)
+		}
+	} else if dm.state == victoryGameState {
+		if dm.firstFrame {
+			dm.restartTimer.Start(dm.now)
+			return
+		}
+		if dm.restartTimer.On(dm.now) {
+			return
+		}
+
+		dm.config.levelId = lobbyLevel
+		dm.config.nextState = lobbyGameState
+		dm.SetState(setupGameState)
+	}
+}
+
+// Deathmatch is scored on kills, so there is nothing to do when a goal is reached.
+func (dm *DeathmatchMode) SetWinningTeam(team uint8) {}
+
+func (dm *DeathmatchMode) Restart(g *Grid) {
+	dm.roundTimer.Stop()
+	dm.BaseGameMode.Restart(g)
+}
+
+func (dm DeathmatchMode) GetUpdates() Data {
+	data := dm.BaseGameMode.GetUpdates()
+	data.Set(limitProp, dm.killLimit)
+
+	if dm.roundTimer.On(dm.now) {
+		remaining := dm.roundTimer.duration - dm.roundTimer.Elapsed(dm.now)
+		data.Set(timerProp, int(remaining / time.Millisecond))
+	}
+	return data
+}
+
+// Drop players that left and put anyone who joined mid-round on the smaller team.
+func (dm *DeathmatchMode) updatePlayers(g *Grid) {
+	for sid, player := range(dm.players) {
+		if g.Get(sid) == nil || player.HasAttribute(deletedAttribute) {
+			delete(dm.players, sid)
+		}
+	}
+
+	for _, player := range(g.GetObjects(playerSpace)) {
+		if _, ok := dm.players[player.GetSpacedId()]; ok {
+			continue
+		}
+
+		team := dm.config.leftTeam
+		if len(dm.teams[dm.config.rightTeam]) < len(dm.teams[team]) {
+			team = dm.config.rightTeam
+		}
+
+		player.(*Player).SetTeam(team)
+		player.AddInternalAttribute(autoRespawnAttribute)
+		player.(*Player).SetSpawn(g)
+		player.Respawn()
+		dm.players[player.GetSpacedId()] = player
+		dm.teams[team] = append(dm.teams[team], player)
+	}
+
+	dm.teams = make(map[uint8][]Object)
+	for _, player := range(dm.players) {
+		team, _ := player.GetByteAttribute(teamByteAttribute)
+		dm.teams[team] = append(dm.teams[team], player)
+	}
+}
+
+// Team scores are kept separately from the player counters so they survive players leaving.
+func (dm *DeathmatchMode) updateScores() {
+	for sid, player := range(dm.players) {
+		team, _ := player.GetByteAttribute(teamByteAttribute)
+		kills, _ := player.GetIntAttribute(killIntAttribute)
+
+		if kills > dm.kills[sid] {
+			dm.teamScores[team] += kills - dm.kills[sid]
+		}
+		dm.kills[sid] = kills
+	}
+}
@@ -27,14 +27,18 @@ func (ec EquipCharger) State() PartStateType {
 }
 
 func (ec *EquipCharger) SetPressed(pressed bool) {
+	// Charging starts from the next update
 	if !ec.pressed && pressed {
-		ec.pressedTime = time.Now()
+		ec.pressedTime = time.Time{}
 	}
 
 	ec.pressed = pressed
 }
 
 func (ec *EquipCharger) Update(grid *Grid, now time.Time) {
+	if ec.pressed && ec.pressedTime.IsZero() {
+		ec.pressedTime = now
+	}
 	if ec.state == activePartState && !ec.equip.HasAttribute(chargedAttribute) {
 		ec.state = readyPartState
 		ec.pressedTime = now
@@ -28,10 +28,10 @@ func NewExpiration() Expiration {
 	}
 }
 
-func (e *Expiration) SetConstantTTL(ttl time.Duration) {
+func (e *Expiration) SetConstantTTL(ttl time.Duration, now time.Time) {
 	e.mode = constantExpirationMode
 
-	e.startTime = time.Now()
+	e.startTime = now
 	e.ttl = ttl
 }
 
@@ -45,13 +45,13 @@ func (e *Expiration) RemoveTTL() {
 	e.mode = unknownExpirationMode
 }
 
-func (e Expiration) Expired() bool {
+func (e Expiration) Expired(now time.Time) bool {
 	if isWasm {
 		return false
 	}
 
 	if e.mode == constantExpirationMode {
-		return time.Now().Sub(e.startTime) >= e.ttl
+		return now.Sub(e.startTime) >= e.ttl
 	}
 
 	if e.mode == variableExpirationMode {
@@ -62,7 +62,7 @@ func (e *Explosion) Update(grid *Grid, now time.Time) {
 		return
 	}
 
-	if e.Expired() {
+	if e.Expired(now) {
 		grid.Delete(e.GetSpacedId())
 		return
 	}
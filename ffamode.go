@@ -0,0 +1,229 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	ffaStartTime time.Duration = 10 * time.Second
+)
+
+type FfaMode struct {
+	DeathmatchMode
+
+	// Number of teams to split players into, or 0 if everyone is on their own team
+	numTeams uint8
+	startTimer Timer
+	nextSpawn int
+}
+
+func NewFfaMode() *FfaMode {
+	mode := &FfaMode {
+		DeathmatchMode: *NewDeathmatchMode(),
+
+		numTeams: 0,
+		startTimer: NewTimer(ffaStartTime),
+		nextSpawn: 0,
+	}
+	mode.id = ffaGameMode
+	return mode
+}
+
+func (fm *FfaMode) SetNumTeams(numTeams uint8) {
+	fm.numTeams = numTeams
+}
+
+func (fm FfaMode) GetNumTeams() uint8 {
+	return fm.numTeams
+}
+
+func (fm *FfaMode) Update(g *Grid) {
+	fm.BaseGameMode.Update(g)
+
+	if fm.state == lobbyGameState {
+		if fm.firstFrame {
+			fm.resetPlayers(g)
+		}
+
+		players := g.GetObjects(playerSpace)
+		fm.updateTeams(players)
+
+		// Teams are assigned automatically, so just wait a bit once there is someone to fight
+		if len(players) < 2 {
+			fm.startTimer.Stop()
+			return
+		}
+		if !fm.startTimer.Started() {
+			fm.startTimer.Start(fm.now)
+		}
+		if !fm.startTimer.Finished(fm.now) {
+			return
+		}
+		fm.startTimer.Stop()
+
+		fm.players = make(map[SpacedId]Object)
+		fm.teamScores = make(map[uint8]int)
+		for i, player := range(sortById(players)) {
+			team := uint8(i + 1)
+			if fm.numTeams > 0 {
+				// Keep any team picked in the lobby
+				team, _ = player.GetByteAttribute(teamByteAttribute)
+				if team == 0 || team > fm.numTeams {
+					team = uint8(i % int(fm.numTeams) + 1)
+				}
+			}
+
+			player.(*Player).SetTeam(team)
+			player.SetIntAttribute(killIntAttribute, 0)
+			player.SetIntAttribute(deathIntAttribute, 0)
+			fm.players[player.GetSpacedId()] = player
+			fm.teamScores[team] = 0
+		}
+		fm.updateTeams(players)
+
+		fm.kills = make(map[SpacedId]int)
+		fm.winningTeam = 0
+		fm.nextSpawn = 0
+		fm.config = GameModeConfig {
+			leftTeam: 1,
+			rightTeam: 2,
+			reverse: false,
+			nextState: activeGameState,
+			levelId: birdTownLevel,
+		}
+		fm.SetState(setupGameState)
+	} else if fm.state == activeGameState {
+		if fm.firstFrame {
+			for _, player := range(sortById(g.GetObjects(playerSpace))) {
+				if _, ok := fm.players[player.GetSpacedId()]; !ok {
+					continue
+				}
+				player.AddInternalAttribute(autoRespawnAttribute)
+				fm.setSpawn(g, player.(*Player))
+				player.Respawn()
+			}
+			fm.roundTimer.Start(fm.now)
+		}
+
+		fm.updatePlayers(g)
+		if len(fm.players) < 2 {
+			fm.roundTimer.Stop()
+			fm.config.levelId = lobbyLevel
+			fm.config.nextState = lobbyGameState
+			fm.SetState(setupGameState)
+			return
+		}
+
+		fm.updateScores()
+		leader := fm.getLeadingTeam()
+		if (leader != 0 && fm.teamScores[leader] >= fm.killLimit) || fm.roundTimer.Finished(fm.now) {
+			fm.winningTeam = leader
+			fm.roundTimer.Stop()
+			fm.SetState(victoryGameState)
+		}
+	} else if fm.state == victoryGameState {
+		if fm.firstFrame {
+			fm.restartTimer.Start(fm.now)
+			return
+		}
+		if fm.restartTimer.On(fm.now) {
+			return
+		}
+
+		fm.config.levelId = lobbyLevel
+		fm.config.nextState = lobbyGameState
+		fm.SetState(setupGameState)
+	}
+}
+
+func (fm *FfaMode) Restart(g *Grid) {
+	fm.startTimer.Stop()
+	fm.DeathmatchMode.Restart(g)
+}
+
+func (fm FfaMode) GetUpdates() Data {
+	data := fm.DeathmatchMode.GetUpdates()
+
+	if fm.state == lobbyGameState && fm.startTimer.On(fm.now) {
+		remaining := fm.startTimer.duration - fm.startTimer.Elapsed(fm.now)
+		data.Set(timerProp, int(remaining / time.Millisecond))
+	}
+	return data
+}
+
+// Drop players that left and give anyone who joined mid-round a team and a spawn.
+func (fm *FfaMode) updatePlayers(g *Grid) {
+	for sid, player := range(fm.players) {
+		if g.Get(sid) == nil || player.HasAttribute(deletedAttribute) {
+			delete(fm.players, sid)
+		}
+	}
+	fm.updateTeams(g.GetObjects(playerSpace))
+
+	for _, player := range(sortById(g.GetObjects(playerSpace))) {
+		if _, ok := fm.players[player.GetSpacedId()]; ok {
+			continue
+		}
+
+		team := fm.nextTeam()
+		player.(*Player).SetTeam(team)
+		player.AddInternalAttribute(autoRespawnAttribute)
+		fm.setSpawn(g, player.(*Player))
+		player.Respawn()
+
+		fm.players[player.GetSpacedId()] = player
+		fm.teams[team] = append(fm.teams[team], player)
+		if _, ok := fm.teamScores[team]; !ok || fm.numTeams == 0 {
+			// Without fixed teams the number may have belonged to someone who left, so don't
+			// inherit their score
+			fm.teamScores[team] = 0
+		}
+	}
+
+	fm.teams = make(map[uint8][]Object)
+	for _, player := range(fm.players) {
+		team, _ := player.GetByteAttribute(teamByteAttribute)
+		fm.teams[team] = append(fm.teams[team], player)
+	}
+}
+
+// Smallest team if the number of teams is fixed, otherwise the lowest unused team.
+func (fm FfaMode) nextTeam() uint8 {
+	if fm.numTeams == 0 {
+		team := uint8(1)
+		for len(fm.teams[team]) > 0 {
+			team += 1
+		}
+		return team
+	}
+
+	team := uint8(1)
+	for i := uint8(2); i <= fm.numTeams; i += 1 {
+		if len(fm.teams[i]) < len(fm.teams[team]) {
+			team = i
+		}
+	}
+	return team
+}
+
+// Spawns are handed out round-robin regardless of which team the level assigned them to.
+func (fm *FfaMode) setSpawn(g *Grid, player *Player) {
+	spawns := sortById(g.GetObjects(spawnSpace))
+	if len(spawns) == 0 {
+		return
+	}
+
+	player.SetSpawnAt(spawns[fm.nextSpawn % len(spawns)])
+	fm.nextSpawn += 1
+}
+
+// Map order is random, so sort anything that should be assigned in a stable order.
+func sortById(objects map[IdType]Object) []Object {
+	sorted := make([]Object, 0, len(objects))
+	for _, object := range(objects) {
+		sorted = append(sorted, object)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].GetId() < sorted[j].GetId() })
+	return sorted
+}
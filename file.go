@@ -5,6 +5,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func serveFiles(dir string) {
@@ -36,4 +38,55 @@ func serveFiles(dir string) {
 
 func notFound(w http.ResponseWriter) {
 	fmt.Fprintf(w, "404!")
-}
\ No newline at end of file
+}
+const (
+	replayDir string = "replays"
+	replayExtension string = ".replay"
+	maxReplayNameLength int = 64
+)
+
+func SaveReplay(replay *Replay) (string, error) {
+	err := os.MkdirAll(replayDir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%d%s", filepath.Base(replay.Room), replay.Start, replayExtension)
+	file := filepath.Join(replayDir, name)
+	return file, os.WriteFile(file, Pack(replay), 0644)
+}
+
+// Names have to look like the ones SaveReplay writes so they can't point outside the replay directory.
+func ValidReplayName(name string) bool {
+	if len(name) <= len(replayExtension) || len(name) > maxReplayNameLength || !strings.HasSuffix(name, replayExtension) {
+		return false
+	}
+	for _, c := range(name) {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return !strings.HasPrefix(name, ".")
+}
+
+// Only loads files from the replay directory.
+func LoadReplay(name string) (*Replay, error) {
+	if !ValidReplayName(name) {
+		return nil, fmt.Errorf("invalid replay name %s", name)
+	}
+
+	b, err := os.ReadFile(filepath.Join(replayDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	replay := &Replay{}
+	err = Unpack(b, replay)
+	if err != nil {
+		return nil, err
+	}
+	if replay.V != gameVersion {
+		return nil, fmt.Errorf("replay is from version %s, current version is %s", replay.V, gameVersion)
+	}
+	return replay, nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -22,6 +24,21 @@ type Game struct {
 	grid *Grid
 	level *Level
 	seqNum SeqNumType
+	clock *Clock
+	snapshots *SnapshotTracker
+
+	bots map[IdType]*Bot
+	inputs map[IdType]*InputBuffer
+
+	// Set if inputs to this game are being recorded
+	replay *Replay
+	// Levels without a queued seed get one derived from this, so replays load the same levels
+	seed int64
+	// Replays of matches that ended, waiting to be saved
+	finishedReplays []*Replay
+	seedQueue []LevelSeedType
+	// Played whenever the game mode leaves the lobby
+	matchLevel LevelIdType
 }
 
 func NewGame() *Game {
@@ -30,6 +47,17 @@ func NewGame() *Game {
 		grid: grid,
 		level: NewLevel(),
 		seqNum: 0,
+		clock: NewClock(),
+		snapshots: NewSnapshotTracker(),
+
+		bots: make(map[IdType]*Bot),
+		inputs: make(map[IdType]*InputBuffer),
+
+		replay: nil,
+		seed: 0,
+		finishedReplays: make([]*Replay, 0),
+		seedQueue: make([]LevelSeedType, 0),
+		matchLevel: birdTownLevel,
 	}
 	return game
 }
@@ -78,14 +106,275 @@ func (g Game) GetGrid() *Grid {
 
 func (g *Game) LoadLevel(id LevelIdType, seed LevelSeedType) {
 	g.level.LoadLevel(id, seed, g.grid)
+
+	if g.replay != nil {
+		g.replay.RecordLevel(g.seqNum, id, g.level.GetSeed())
+	}
+}
+
+// Use the seed for the next level the game mode loads instead of a random one.
+func (g *Game) QueueLevelSeed(seed LevelSeedType) {
+	g.seedQueue = append(g.seedQueue, seed)
+}
+
+func (g *Game) SetSeed(seed int64) {
+	g.seed = seed
+}
+
+func (g *Game) SetMatchLevel(id LevelIdType) {
+	g.matchLevel = id
+
+	// Replays split when the lobby loads, so the current one hasn't reached its match yet
+	if g.replay != nil && g.level.GetId() == lobbyLevel {
+		g.replay.MatchLevel = id
+	}
+}
+
+func (g Game) GetMatchLevel() LevelIdType {
+	return g.matchLevel
+}
+
+func (g *Game) nextLevelSeed() LevelSeedType {
+	if len(g.seedQueue) > 0 {
+		seed := g.seedQueue[0]
+		g.seedQueue = g.seedQueue[1:]
+		return seed
+	}
+
+	r := rand.New(rand.NewSource(g.seed))
+	g.seed = r.Int63()
+	return LevelSeedType(r.Int63() % 3333333)
+}
+
+func (g *Game) StartRecording(room string) {
+	g.replay = NewReplay(room, g.seqNum)
+	g.replay.Seed = g.seed
+	g.replay.Mode = g.grid.GetGameModeId()
+	g.replay.ModeOptions = copyModeOptions(g.grid.GetModeOptions())
+	g.replay.MatchLevel = g.matchLevel
+}
+
+// Finish the current replay and start a new one with everyone who is still here.
+func (g *Game) splitReplay() {
+	if g.replay == nil {
+		return
+	}
+
+	g.finishedReplays = append(g.finishedReplays, g.replay)
+	g.StartRecording(g.replay.Room)
+
+	for _, id := range(g.getInputIds()) {
+		player := g.grid.Get(Id(playerSpace, id)).(*Player)
+		name, _ := player.GetInitData().Get(nameProp).(string)
+		g.replay.RecordJoin(g.seqNum, id, name, false)
+		g.replay.RecordPing(g.seqNum, id, player.GetPing())
+	}
+	for _, id := range(g.GetBotIds()) {
+		name, _ := g.grid.Get(Id(playerSpace, id)).GetInitData().Get(nameProp).(string)
+		g.replay.RecordJoin(g.seqNum, id, name, true)
+	}
+}
+
+func copyModeOptions(modeOptions map[GameModeIdType]map[string]int) map[GameModeIdType]map[string]int {
+	copied := make(map[GameModeIdType]map[string]int, len(modeOptions))
+	for mode, options := range(modeOptions) {
+		copied[mode] = make(map[string]int, len(options))
+		for name, value := range(options) {
+			copied[mode][name] = value
+		}
+	}
+	return copied
+}
+
+func (g *Game) TakeFinishedReplays() []*Replay {
+	replays := g.finishedReplays
+	g.finishedReplays = make([]*Replay, 0)
+	return replays
+}
+
+func (g Game) GetReplay() *Replay {
+	return g.replay
+}
+
+// Creates a player or cancels the pending removal of one that reconnected.
+func (g *Game) JoinPlayer(id IdType, name string) (*Player, bool) {
+	sid := Id(playerSpace, id)
+	reconnected := g.Has(sid)
+
+	var player *Player
+	if reconnected {
+		player = g.Get(sid).(*Player)
+		player.RemoveTTL()
+		player.ResetSeqNum()
+	} else {
+		player = g.newPlayer(id, name)
+	}
+
+	// Clients start counting inputs from scratch when they connect
+	g.inputs[id] = NewInputBuffer()
+
+	if g.replay != nil {
+		g.replay.RecordJoin(g.seqNum, id, name, false)
+	}
+	return player, reconnected
+}
+
+// Players stick around for a bit in case they reconnect.
+func (g *Game) LeavePlayer(id IdType) {
+	player := g.Get(Id(playerSpace, id))
+	if player != nil {
+		player.SetConstantTTL(10 * time.Second, g.clock.Now())
+	}
+	delete(g.inputs, id)
+
+	if g.replay != nil {
+		g.replay.RecordLeave(g.seqNum, id)
+	}
+}
+
+func (g *Game) AddBot(id IdType, name string) {
+	if isWasm {
+		panic("addBot called in WASM")
+	}
+
+	player := g.newPlayer(id, name)
+	player.SetInitProp(botProp, true)
+
+	g.bots[id] = NewBot(id)
+	if g.replay != nil {
+		g.replay.RecordJoin(g.seqNum, id, name, true)
+	}
+}
+
+func (g *Game) RemoveBot(id IdType) {
+	if _, ok := g.bots[id]; !ok {
+		return
+	}
+
+	g.Delete(Id(playerSpace, id))
+	delete(g.bots, id)
+
+	if g.replay != nil {
+		g.replay.RecordLeave(g.seqNum, id)
+	}
+}
+
+func (g Game) HasBot(id IdType) bool {
+	_, ok := g.bots[id]
+	return ok
+}
+
+// Sorted by id so the newest bot is last
+func (g Game) GetBotIds() []IdType {
+	ids := make([]IdType, 0, len(g.bots))
+	for id := range(g.bots) {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (g Game) getInputIds() []IdType {
+	ids := make([]IdType, 0, len(g.inputs))
+	for id := range(g.inputs) {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Returns and resets the number of client inputs that were never applied.
+func (g *Game) FlushSkippedInputs() int {
+	skipped := 0
+	for _, inputs := range(g.inputs) {
+		skipped += inputs.FlushSkipped()
+	}
+	return skipped
+}
+
+func (g *Game) newPlayer(id IdType, name string) *Player {
+	player := g.Add(NewInit(Id(playerSpace, id), NewVec2(0, 0), NewVec2(0.8, 1.44))).(*Player)
+	player.SetInitProp(nameProp, name)
+	player.SetTeam(0)
+	player.SetSpawn(g.grid)
+	player.Respawn()
+	return player
 }
 
+// The server buffers inputs and applies one per tick, WASM predicts with them right away.
 func (g *Game) ProcessKeyMsg(id IdType, keyMsg KeyMsg) {
+	if isWasm {
+		g.ApplyKeyMsg(id, keyMsg)
+		return
+	}
+
+	if inputs, ok := g.inputs[id]; ok {
+		inputs.Add(keyMsg)
+	}
+}
+
+func (g *Game) ApplyKeyMsg(id IdType, keyMsg KeyMsg) {
 	if !g.grid.Has(Id(playerSpace, id)) {
 		return
 	}
 	player := g.grid.Get(Id(playerSpace, id)).(*Player)
 	player.UpdateKeys(keyMsg)
+
+	// Bot inputs are regenerated during playback
+	if g.replay != nil && !g.HasBot(id) {
+		g.replay.RecordKey(g.seqNum, id, keyMsg)
+	}
+}
+
+func (g *Game) SetPing(id IdType, ping time.Duration) {
+	if !g.grid.Has(Id(playerSpace, id)) {
+		return
+	}
+	player := g.grid.Get(Id(playerSpace, id)).(*Player)
+	if player.GetPing() == ping {
+		return
+	}
+	player.SetPing(ping)
+
+	if g.replay != nil {
+		g.replay.RecordPing(g.seqNum, id, ping)
+	}
+}
+
+// Returns false if there's no such player.
+func (g *Game) SetTeam(id IdType, team uint8) bool {
+	if !g.grid.Has(Id(playerSpace, id)) {
+		return false
+	}
+	g.grid.Get(Id(playerSpace, id)).(*Player).SetTeam(team)
+
+	if g.replay != nil {
+		g.replay.RecordTeam(g.seqNum, id, team)
+	}
+	return true
+}
+
+func (g *Game) SetGameMode(mode GameModeIdType, options map[string]int) {
+	// Point everyone's vote at the new mode so the lobby vote doesn't switch it back
+	for _, player := range(g.grid.GetObjects(playerSpace)) {
+		player.SetByteAttribute(voteByteAttribute, uint8(mode))
+	}
+	if g.grid.GetGameModeId() != mode {
+		g.grid.SetGameMode(mode)
+	}
+	g.grid.SetModeOptions(mode, options)
+
+	if g.replay != nil {
+		g.replay.RecordMode(g.seqNum, mode, options)
+	}
+}
+
+func (g *Game) RestartGameMode() {
+	g.grid.RestartGameMode()
+
+	if g.replay != nil {
+		g.replay.RecordRestart(g.seqNum)
+	}
 }
 
 func (g *Game) Update() map[GameUpdateType]bool {
@@ -95,8 +384,15 @@ func (g *Game) Update() map[GameUpdateType]bool {
 
 	if state == setupGameState {
 		mode := g.grid.GetGameModeConfig()
-		seed := LevelSeedType(UnixMilli() % 3333333)
-		g.level.LoadLevel(mode.levelId, seed, g.grid)
+		levelId := mode.levelId
+		seed := g.nextLevelSeed()
+		if levelId != lobbyLevel {
+			levelId = g.matchLevel
+		} else if g.level.GetId() != lobbyLevel {
+			// The match is over, so it can be saved without the rest of the room's history
+			g.splitReplay()
+		}
+		g.LoadLevel(levelId, seed)
 		g.grid.SetGameState(mode.nextState)
 		updates[levelGameUpdate] = true
 	}
@@ -105,7 +401,18 @@ func (g *Game) Update() map[GameUpdateType]bool {
 		updates[gameStateUpdate] = true
 	}
 
-	now := time.Now()
+	now := g.clock.Advance(frameTime)
+	for _, id := range(g.getInputIds()) {
+		if keyMsg, ok := g.inputs[id].Next(); ok {
+			g.ApplyKeyMsg(id, keyMsg)
+		}
+	}
+	for _, id := range(g.GetBotIds()) {
+		if keyMsg, ok := g.bots[id].GetKeyMsg(g.grid, now); ok {
+			g.ApplyKeyMsg(id, keyMsg)
+		}
+	}
+
 	g.grid.Update(now)
 	updates[objectGameUpdate] = true
 	g.seqNum++
@@ -166,11 +473,19 @@ func (g *Game) createObjectInitMsg() ObjectStateMsg {
 	}
 }
 
-func (g *Game) createObjectDataMsg() ObjectStateMsg {
+// Should be called once per frame before creating any delta messages.
+func (g *Game) updateSnapshot() {
+	g.snapshots.Update(g.seqNum, g.grid.GetObjectData())
+}
+
+// Object data relative to what the client last acked. The baseline is 0 if everything is included.
+func (g *Game) createObjectDataMsg(baseline SeqNumType) ObjectStateMsg {
+	objects, baseline := g.snapshots.Delta(g.seqNum, baseline)
 	return ObjectStateMsg{
 		T: objectDataType,
 		S: g.seqNum,
-		Os: g.grid.GetObjectData(),
+		B: baseline,
+		Os: objects,
 	}
 }
 
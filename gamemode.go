@@ -1,5 +1,10 @@
 package main
 
+import (
+	"sort"
+	"time"
+)
+
 type GameStateType uint8
 const (
 	unknownGameState GameStateType = iota
@@ -9,15 +14,168 @@ const (
 	victoryGameState
 )
 
+type GameModeIdType uint8
+const (
+	unknownGameMode GameModeIdType = iota
+	vipGameMode
+	deathmatchGameMode
+	ctfGameMode
+	ffaGameMode
+)
+
+type GameModeEntry struct {
+	name string
+	color int
+	create func() GameMode
+}
+
+// Every mode that can be voted on in the lobby
+var gameModes = map[GameModeIdType]GameModeEntry {
+	vipGameMode: {
+		name: "vip",
+		color: vipColor,
+		create: func() GameMode { return NewVipMode() },
+	},
+	deathmatchGameMode: {
+		name: "deathmatch",
+		color: deathmatchModeColor,
+		create: func() GameMode { return NewDeathmatchMode() },
+	},
+	ctfGameMode: {
+		name: "ctf",
+		color: ctfModeColor,
+		create: func() GameMode { return NewCtfMode() },
+	},
+	ffaGameMode: {
+		name: "ffa",
+		color: ffaModeColor,
+		create: func() GameMode { return NewFfaMode() },
+	},
+}
+
+func NewGameMode(id GameModeIdType) GameMode {
+	entry, ok := gameModes[id]
+	if !ok {
+		return nil
+	}
+	return entry.create()
+}
+
+func GameModeName(id GameModeIdType) string {
+	entry, ok := gameModes[id]
+	if !ok {
+		return "unknown"
+	}
+	return entry.name
+}
+
+func GameModeByName(name string) (GameModeIdType, bool) {
+	for id, entry := range(gameModes) {
+		if entry.name == name {
+			return id, true
+		}
+	}
+	return unknownGameMode, false
+}
+
+// Set with /mode <mode> name=value
+type ModeOption struct {
+	min int
+	max int
+
+	// Returns false if the mode doesn't have the option
+	apply func(mode GameMode, value int) bool
+}
+
+var modeOptions = map[string]ModeOption {
+	"kills": {
+		min: 1,
+		max: 100,
+		apply: func(mode GameMode, value int) bool {
+			m, ok := mode.(interface{ SetKillLimit(killLimit int) })
+			if ok {
+				m.SetKillLimit(value)
+			}
+			return ok
+		},
+	},
+	"minutes": {
+		min: 1,
+		max: 30,
+		apply: func(mode GameMode, value int) bool {
+			m, ok := mode.(interface{ SetTimeLimit(timeLimit time.Duration) })
+			if ok {
+				m.SetTimeLimit(time.Duration(value) * time.Minute)
+			}
+			return ok
+		},
+	},
+	"score": {
+		min: 1,
+		max: 20,
+		apply: func(mode GameMode, value int) bool {
+			m, ok := mode.(interface{ SetMaxScore(maxScore int) })
+			if ok {
+				m.SetMaxScore(value)
+			}
+			return ok
+		},
+	},
+	// 0 puts everyone on their own team
+	"teams": {
+		min: 0,
+		max: 8,
+		apply: func(mode GameMode, value int) bool {
+			m, ok := mode.(interface{ SetNumTeams(numTeams uint8) })
+			if ok {
+				m.SetNumTeams(uint8(value))
+			}
+			return ok
+		},
+	},
+}
+
+func modeOptionNames() []string {
+	names := make([]string, 0, len(modeOptions))
+	for name := range(modeOptions) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Applies the options in a fixed order, skipping any the mode doesn't have.
+func ApplyModeOptions(mode GameMode, options map[string]int) {
+	for _, name := range(modeOptionNames()) {
+		if value, ok := options[name]; ok {
+			modeOptions[name].apply(mode, value)
+		}
+	}
+}
+
+// Sorted so the lobby is generated the same way on the server and in WASM
+func GameModeIds() []GameModeIdType {
+	ids := make([]GameModeIdType, 0, len(gameModes))
+	for id := range(gameModes) {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
 type GameMode interface {
 	DataMethods
 
+	GetId() GameModeIdType
 	GetConfig() GameModeConfig
 	GetState() (GameStateType, bool)
 	SetState(state GameStateType)
+	GetNumTeams() uint8
 
 	Update(g * Grid)
 	SetWinningTeam(team uint8)
+	GetWinningTeam() uint8
+	Restart(g *Grid)
 }
 
 type GameModeConfig struct {
@@ -30,11 +188,13 @@ type GameModeConfig struct {
 }
 
 type BaseGameMode struct {
+	id GameModeIdType
 	config GameModeConfig
 
 	lastState GameStateType
 	state GameStateType
 	firstFrame bool
+	now time.Time
 
 	players map[SpacedId]Object
 	teams map[uint8][]Object
@@ -43,10 +203,13 @@ type BaseGameMode struct {
 	teamScores map[uint8]int
 }
 
-func NewBaseGameMode() BaseGameMode {
+func NewBaseGameMode(id GameModeIdType) BaseGameMode {
 	return BaseGameMode {
-		lastState: unknownGameState,
-		state: unknownGameState,
+		id: id,
+
+		// Modes start in the lobby and should not reset players when swapped in by a vote
+		lastState: lobbyGameState,
+		state: lobbyGameState,
 		firstFrame: false,
 
 		players: make(map[SpacedId]Object),
@@ -58,10 +221,15 @@ func NewBaseGameMode() BaseGameMode {
 }
 
 func (bgm *BaseGameMode) Update(g * Grid) {
+	bgm.now = g.Now()
 	bgm.firstFrame = bgm.lastState != bgm.state
 	bgm.lastState = bgm.state
 }
 
+func (bgm BaseGameMode) GetId() GameModeIdType {
+	return bgm.id
+}
+
 func (bgm BaseGameMode) GetConfig() GameModeConfig {
 	return bgm.config
 }
@@ -74,6 +242,73 @@ func (bgm *BaseGameMode) SetState(state GameStateType) {
 	bgm.state = state
 }
 
+// Teams players can pick from in the lobby, or 0 if the mode assigns them
+func (bgm BaseGameMode) GetNumTeams() uint8 {
+	return 2
+}
+
+func (bgm BaseGameMode) GetWinningTeam() uint8 {
+	return bgm.winningTeam
+}
+
+// Abandon the current round and head back to the lobby
+func (bgm *BaseGameMode) Restart(g *Grid) {
+	bgm.winningTeam = 0
+	bgm.teamScores = make(map[uint8]int)
+	bgm.config.levelId = lobbyLevel
+	bgm.config.nextState = lobbyGameState
+	bgm.SetState(setupGameState)
+}
+
+// Move everyone back to the neutral team with auto respawn
+func (bgm *BaseGameMode) resetPlayers(g *Grid) {
+	for _, player := range(g.GetObjects(playerSpace)) {
+		player.RemoveAttribute(vipAttribute)
+		player.AddInternalAttribute(autoRespawnAttribute)
+		player.(*Player).SetTeam(0)
+		player.(*Player).SetSpawn(g)
+		player.Respawn()
+	}
+}
+
+func (bgm *BaseGameMode) updateTeams(players map[IdType]Object) {
+	bgm.teams = make(map[uint8][]Object)
+	for _, player := range(players) {
+		team, _ := player.GetByteAttribute(teamByteAttribute)
+		bgm.teams[team] = append(bgm.teams[team], player)
+	}
+	bgm.sortTeams()
+}
+
+// Players are collected from maps, so sort them to pick the same ones every time the game is replayed.
+func (bgm *BaseGameMode) sortTeams() {
+	for _, team := range(bgm.teams) {
+		sort.Slice(team, func(i, j int) bool { return team[i].GetId() < team[j].GetId() })
+	}
+}
+
+// Returns the team with the highest score, or 0 if there is a tie
+func (bgm BaseGameMode) getLeadingTeam() uint8 {
+	leader := uint8(0)
+	tie := false
+	for team, score := range(bgm.teamScores) {
+		if team == 0 {
+			continue
+		}
+		if leader == 0 || score > bgm.teamScores[leader] {
+			leader = team
+			tie = false
+		} else if score == bgm.teamScores[leader] {
+			tie = true
+		}
+	}
+
+	if tie {
+		return 0
+	}
+	return leader
+}
+
 func (bgm* BaseGameMode) SetData(data Data) {
 	if data.Has(stateProp) {
 		bgm.SetState(data.Get(stateProp).(GameStateType))
@@ -90,6 +325,7 @@ func (bgm BaseGameMode) GetData() Data {
 
 func (bgm BaseGameMode) GetUpdates() Data {
 	data := NewData()
+	data.Set(modeProp, bgm.id)
 	data.Set(stateProp, bgm.state)
 	data.Set(scoreProp, bgm.teamScores)
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"time"
 )
 
@@ -24,6 +25,10 @@ type Grid struct {
 	unitHeight int
 
 	gameMode GameMode
+	gameModeChanged bool
+	// Set with /mode and applied whenever that mode is created again
+	modeOptions map[GameModeIdType]map[string]int
+	now time.Time
 
 	lastId map[SpaceType]IdType
 	objects map[SpacedId]Object
@@ -31,6 +36,9 @@ type Grid struct {
 
 	grid map[GridCoord]map[SpacedId]Object
 	reverseGrid map[SpacedId][]GridCoord
+
+	history *ProfileHistory
+	stats *MatchStats
 }
 
 func NewGrid(unitLength int, unitHeight int) *Grid {
@@ -38,13 +46,18 @@ func NewGrid(unitLength int, unitHeight int) *Grid {
 		unitLength: unitLength,
 		unitHeight: unitHeight,
 
-		gameMode: NewVipMode(),
+		gameMode: NewGameMode(vipGameMode),
+		gameModeChanged: false,
+		modeOptions: make(map[GameModeIdType]map[string]int),
 
 		lastId: make(map[SpaceType]IdType, 0),
 		objects: make(map[SpacedId]Object, 0),
 		spacedObjects: make(map[SpaceType]map[IdType]Object, 0),
 		grid: make(map[GridCoord]map[SpacedId]Object, 0),
 		reverseGrid: make(map[SpacedId][]GridCoord, 0),
+
+		history: NewProfileHistory(),
+		stats: NewMatchStats(),
 	}
 }
 
@@ -56,11 +69,58 @@ func (g *Grid) GetUnitHeight() int {
 	return g.unitHeight
 }
 
-func (g Grid) GetGameState() (GameStateType, bool) { return g.gameMode.GetState() }
+func (g Grid) GetGameState() (GameStateType, bool) {
+	state, changed := g.gameMode.GetState()
+	return state, changed || g.gameModeChanged
+}
+func (g Grid) Now() time.Time { return g.now }
+func (g Grid) GetGameMode() GameMode { return g.gameMode }
+func (g Grid) GetGameModeId() GameModeIdType { return g.gameMode.GetId() }
 func (g Grid) GetGameModeConfig() GameModeConfig { return g.gameMode.GetConfig() }
+func (g Grid) GetNumTeams() uint8 { return g.gameMode.GetNumTeams() }
 func (g *Grid) SetGameState(state GameStateType) { g.gameMode.SetState(state) }
 func (g *Grid) SetWinningTeam(team uint8) { g.gameMode.SetWinningTeam(team) }
-func (g Grid) GetGameStateProps() PropMap { return g.gameMode.GetUpdates().Props() }
+func (g *Grid) RestartGameMode() { g.gameMode.Restart(g) }
+func (g Grid) GetStats() *MatchStats { return g.stats }
+
+// The scoreboard is only sent once a round is over
+func (g Grid) GetGameStateProps() PropMap {
+	props := g.gameMode.GetUpdates().Props()
+	if state, _ := g.GetGameState(); state == victoryGameState {
+		props[scoreboardProp] = g.stats.Scoreboard()
+	}
+	return props
+}
+
+func (g *Grid) SetGameMode(id GameModeIdType) {
+	mode := NewGameMode(id)
+	if mode == nil {
+		Log(fmt.Sprintf("Unknown game mode: %d", id))
+		return
+	}
+
+	ApplyModeOptions(mode, g.modeOptions[id])
+	g.gameMode = mode
+	g.gameModeChanged = true
+}
+
+func (g Grid) GetModeOptions() map[GameModeIdType]map[string]int {
+	return g.modeOptions
+}
+
+// Options not given keep their previous value.
+func (g *Grid) SetModeOptions(id GameModeIdType, options map[string]int) {
+	if _, ok := g.modeOptions[id]; !ok {
+		g.modeOptions[id] = make(map[string]int)
+	}
+	for name, value := range(options) {
+		g.modeOptions[id][name] = value
+	}
+
+	if g.gameMode.GetId() == id {
+		ApplyModeOptions(g.gameMode, options)
+	}
+}
 
 func (g *Grid) New(init Init) Object {
 	switch init.GetSpace() {
@@ -104,6 +164,8 @@ func (g *Grid) New(init Init) Object {
 		return NewGoal(init)
 	case spawnSpace:
 		return NewSpawn(init)
+	case teamFlagSpace:
+		return NewTeamFlag(init)
 	default:
 		Log(fmt.Sprintf("Unknown space! %+v", init))
 		return nil
@@ -176,12 +238,21 @@ func (g *Grid) deleteCoords(sid SpacedId) {
 }
 
 func (g *Grid) Update(now time.Time) {
+	g.now = now
 	if !isWasm {
+		g.gameModeChanged = false
+		lastState, _ := g.GetGameState()
+		if lastState == lobbyGameState {
+			g.updateGameModeVote()
+		}
 		g.gameMode.Update(g)
+		g.updateStats(lastState)
 	}
 	gameState, _ := g.GetGameState()
 
-	for _, object := range(g.GetAllObjects()) {
+	// Update in a fixed order so the simulation is reproducible
+	objects := g.getSortedObjects()
+	for _, object := range(objects) {
 		if gameState == victoryGameState {
 			object.SetUpdateSpeed(0.3)
 		} else {
@@ -190,21 +261,87 @@ func (g *Grid) Update(now time.Time) {
 		object.PreUpdate(g, now)
 	}
 
-	for _, object := range(g.GetAllObjects()) {
+	for _, object := range(objects) {
 		if object.GetSpace() == playerSpace {
 			continue
 		}
 		object.Update(g, now)
 	}
 
-	// Map iteration is random
-	for _, object := range(g.GetObjects(playerSpace)) {
+	// Players go last so they see everything else in its updated state
+	for _, object := range(objects) {
+		if object.GetSpace() != playerSpace {
+			continue
+		}
 		object.Update(g, now)
 	}
 
-	for _, object := range(g.GetAllObjects()) {
+	for _, object := range(objects) {
 		object.PostUpdate(g, now)
 	}
+
+	if !isWasm {
+		g.history.Record(now, g.GetObjects(playerSpace))
+	}
+}
+
+// Start fresh stats when a match leaves the lobby and credit the winners when a round ends.
+func (g *Grid) updateStats(lastState GameStateType) {
+	state, changed := g.gameMode.GetState()
+	if !changed {
+		return
+	}
+
+	if lastState == lobbyGameState && state == setupGameState && g.GetGameModeConfig().levelId != lobbyLevel {
+		g.stats.Reset()
+	} else if state == victoryGameState {
+		g.stats.RecordWin(g.gameMode.GetWinningTeam(), g.GetObjects(playerSpace))
+	}
+}
+
+// Returns the object's hitbox from the given time, if it was recorded.
+func (g *Grid) GetPastProfile(sid SpacedId, t time.Time) (Profile, bool) {
+	return g.history.Get(sid, t)
+}
+
+func (g *Grid) ClearHistory() {
+	g.history.Clear()
+}
+
+func (g *Grid) getSortedObjects() []Object {
+	objects := make([]Object, 0, len(g.objects))
+	for _, object := range(g.objects) {
+		objects = append(objects, object)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].GetSpace() != objects[j].GetSpace() {
+			return objects[i].GetSpace() < objects[j].GetSpace()
+		}
+		return objects[i].GetId() < objects[j].GetId()
+	})
+	return objects
+}
+
+// Switch to the mode with the most votes. Ties keep the current mode.
+func (g *Grid) updateGameModeVote() {
+	votes := make(map[GameModeIdType]int)
+	for _, player := range(g.GetObjects(playerSpace)) {
+		if vote, ok := player.GetByteAttribute(voteByteAttribute); ok && vote != uint8(unknownGameMode) {
+			votes[GameModeIdType(vote)] += 1
+		}
+	}
+
+	current := g.gameMode.GetId()
+	best := current
+	for _, id := range(GameModeIds()) {
+		if votes[id] > votes[best] {
+			best = id
+		}
+	}
+
+	if best != current {
+		g.SetGameMode(best)
+	}
 }
 
 func (g *Grid) updateObject(object Object, now time.Time) {
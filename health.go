@@ -56,30 +56,29 @@ func (h Health) Dead() bool {
 	return h.health <= 0
 }
 
-func (h Health) GetLastTicks(duration time.Duration) []DamageTick {
-	currentTime := time.Now()
+func (h Health) GetLastTicks(duration time.Duration, now time.Time) []DamageTick {
 	for i, tick := range(h.ticks) {
-		if currentTime.Sub(tick.time) <= duration {
+		if now.Sub(tick.time) <= duration {
 			return h.ticks[i:]
 		}
 	}
 	return make([]DamageTick, 0)
 }
 
-func (h Health) GetLastDamageId(duration time.Duration) SpacedId {
+func (h Health) GetLastDamageId(duration time.Duration, now time.Time) SpacedId {
 	if len(h.ticks) == 0 {
 		return InvalidId()
 	}
 
 	tick := h.ticks[len(h.ticks)-1]
 
-	if time.Now().Sub(tick.time) <= duration {
+	if now.Sub(tick.time) <= duration {
 		return tick.sid
 	}
 	return InvalidId()
 }
 
-func (h *Health) TakeDamage(sid SpacedId, damage int) {
+func (h *Health) TakeDamage(sid SpacedId, damage int, now time.Time) {
 	if !h.enabled || h.Dead() || isWasm || damage == 0 {
 		return
 	}
@@ -88,7 +87,7 @@ func (h *Health) TakeDamage(sid SpacedId, damage int) {
 	tick := DamageTick {
 		sid: sid,
 		damage: damage,
-		time: time.Now(),
+		time: now,
 	}
 	h.ticks = append(h.ticks, tick)
 
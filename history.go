@@ -0,0 +1,81 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// Shots are never rewound further than this
+	maxRewindTime time.Duration = 250 * time.Millisecond
+)
+
+type HistoryFrame struct {
+	now time.Time
+	profiles map[SpacedId]Profile
+}
+
+// Ring buffer of past player hitboxes so shots can be checked against what the shooter saw.
+type ProfileHistory struct {
+	frames []HistoryFrame
+	next int
+}
+
+func NewProfileHistory() *ProfileHistory {
+	return &ProfileHistory {
+		frames: make([]HistoryFrame, int(maxRewindTime / frameTime) + 1),
+		next: 0,
+	}
+}
+
+func (ph *ProfileHistory) Record(now time.Time, objects map[IdType]Object) {
+	profiles := make(map[SpacedId]Profile, len(objects))
+	for _, object := range(objects) {
+		if object.HasAttribute(deadAttribute) {
+			continue
+		}
+		profiles[object.GetSpacedId()] = NewRec2(NewInit(object.GetSpacedId(), object.Pos(), object.Dim()))
+	}
+
+	ph.frames[ph.next] = HistoryFrame {
+		now: now,
+		profiles: profiles,
+	}
+	ph.next = (ph.next + 1) % len(ph.frames)
+}
+
+// Returns the hitbox from the latest frame at or before the given time, or the oldest one if
+// the time is too far back. Objects that didn't exist back then aren't returned.
+func (ph ProfileHistory) Get(sid SpacedId, t time.Time) (Profile, bool) {
+	var best *HistoryFrame
+	for i := range(ph.frames) {
+		frame := &ph.frames[i]
+		if frame.now.IsZero() {
+			continue
+		}
+		if best == nil {
+			best = frame
+			continue
+		}
+
+		if frame.now.After(t) {
+			if best.now.After(t) && frame.now.Before(best.now) {
+				best = frame
+			}
+		} else if best.now.After(t) || frame.now.After(best.now) {
+			best = frame
+		}
+	}
+
+	if best == nil {
+		return nil, false
+	}
+	profile, ok := best.profiles[sid]
+	return profile, ok
+}
+
+func (ph *ProfileHistory) Clear() {
+	for i := range(ph.frames) {
+		ph.frames[i] = HistoryFrame{}
+	}
+	ph.next = 0
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Hex encoded key for signing reconnect tokens. Without it tokens only last until the server restarts.
+	reconnectSecretEnv string = "RECONNECT_SECRET"
+
+	maxReconnectTokenLength int = 64
+
+	// How long someone can be gone and still get their name and score back
+	identityTTL time.Duration = 10 * time.Minute
+)
+
+var reconnectSecret = loadReconnectSecret()
+
+func loadReconnectSecret() []byte {
+	if secret, err := hex.DecodeString(os.Getenv(reconnectSecretEnv)); err == nil && len(secret) > 0 {
+		return secret
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("Failed to generate reconnect secret: %v", err)
+	}
+	return secret
+}
+
+// Tokens are tied to one instance of a room, so a new room with the same name won't accept old ones.
+func signReconnectToken(room string, created time.Time, id IdType) string {
+	mac := hmac.New(sha256.New, reconnectSecret)
+	mac.Write([]byte(room))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(created.UnixNano(), 10)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.Itoa(int(id))))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifyReconnectToken(room string, created time.Time, id IdType, token string) bool {
+	if len(token) == 0 || len(token) > maxReconnectTokenLength {
+		return false
+	}
+	expected := signReconnectToken(room, created, id)
+	return hmac.Equal([]byte(token), []byte(expected))
+}
+
+// What a player had when they left, restored when they come back with their token.
+type Identity struct {
+	name string
+	team uint8
+	kills int
+	deaths int
+	left time.Time
+}
+
+type IdentityStore struct {
+	identities map[IdType]Identity
+
+	// IDs whose reconnect tokens are no longer accepted, e.g. after a kick
+	revoked map[IdType]bool
+}
+
+func NewIdentityStore() *IdentityStore {
+	return &IdentityStore {
+		identities: make(map[IdType]Identity),
+		revoked: make(map[IdType]bool),
+	}
+}
+
+func (is *IdentityStore) Save(id IdType, name string, player Object, now time.Time) {
+	identity := Identity {
+		name: name,
+		left: now,
+	}
+	if player != nil {
+		identity.team, _ = player.GetByteAttribute(teamByteAttribute)
+		identity.kills, _ = player.GetIntAttribute(killIntAttribute)
+		identity.deaths, _ = player.GetIntAttribute(deathIntAttribute)
+	}
+	is.identities[id] = identity
+}
+
+// Returns and forgets the identity so it's only restored once.
+func (is *IdentityStore) Take(id IdType, now time.Time) (Identity, bool) {
+	identity, ok := is.identities[id]
+	if !ok {
+		return Identity{}, false
+	}
+
+	delete(is.identities, id)
+	if now.Sub(identity.left) > identityTTL {
+		return Identity{}, false
+	}
+	return identity, true
+}
+
+// Forget the identity and stop accepting the token for the ID.
+func (is *IdentityStore) Revoke(id IdType) {
+	delete(is.identities, id)
+	is.revoked[id] = true
+}
+
+func (is IdentityStore) IsRevoked(id IdType) bool {
+	return is.revoked[id]
+}
+
+func (is *IdentityStore) Prune(now time.Time) {
+	for id, identity := range(is.identities) {
+		if now.Sub(identity.left) > identityTTL {
+			delete(is.identities, id)
+		}
+	}
+}
+
+// Put the saved team and score back on a player whose object already expired.
+func (i Identity) Restore(player *Player) {
+	player.SetTeam(i.team)
+	player.SetIntAttribute(killIntAttribute, i.kills)
+	player.SetIntAttribute(deathIntAttribute, i.deaths)
+}
@@ -24,6 +24,7 @@ const (
 	portalSpace
 	goalSpace
 	spawnSpace
+	teamFlagSpace
 )
 
 type SpacedId struct {
@@ -0,0 +1,77 @@
+package main
+
+const (
+	// Inputs past this are dropped oldest first so a burst can't delay the player for long
+	maxBufferedInputs int = 6
+)
+
+// Key messages from one client in the order they should be applied, one per tick.
+// Messages arrive over an unordered channel and are also resent reliably when keys
+// change, so duplicates and anything older than what's already applied are dropped.
+type InputBuffer struct {
+	msgs []KeyMsg
+	lastApplied SeqNumType
+
+	// Inputs that never arrived or were dropped before a later one was applied
+	skipped int
+}
+
+func NewInputBuffer() *InputBuffer {
+	return &InputBuffer {
+		msgs: make([]KeyMsg, 0, maxBufferedInputs),
+		lastApplied: 0,
+	}
+}
+
+// Returns false if the message was a duplicate or arrived too late.
+func (ib *InputBuffer) Add(msg KeyMsg) bool {
+	if msg.S <= ib.lastApplied {
+		return false
+	}
+
+	i := 0
+	for ; i < len(ib.msgs); i += 1 {
+		if ib.msgs[i].S == msg.S {
+			return false
+		}
+		if ib.msgs[i].S > msg.S {
+			break
+		}
+	}
+
+	ib.msgs = append(ib.msgs, KeyMsg{})
+	copy(ib.msgs[i + 1:], ib.msgs[i:])
+	ib.msgs[i] = msg
+
+	if len(ib.msgs) > maxBufferedInputs {
+		ib.msgs = ib.msgs[len(ib.msgs) - maxBufferedInputs:]
+	}
+	return true
+}
+
+// Returns the next input to apply. If nothing arrived the player keeps its last input.
+func (ib *InputBuffer) Next() (KeyMsg, bool) {
+	if len(ib.msgs) == 0 {
+		return KeyMsg{}, false
+	}
+
+	msg := ib.msgs[0]
+	ib.msgs = ib.msgs[1:]
+
+	if ib.lastApplied > 0 && msg.S > ib.lastApplied + 1 {
+		ib.skipped += int(msg.S - ib.lastApplied - 1)
+	}
+	ib.lastApplied = msg.S
+	return msg, true
+}
+
+func (ib InputBuffer) LastApplied() SeqNumType {
+	return ib.lastApplied
+}
+
+// Returns and resets the number of inputs skipped since the last call.
+func (ib *InputBuffer) FlushSkipped() int {
+	skipped := ib.skipped
+	ib.skipped = 0
+	return skipped
+}
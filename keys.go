@@ -24,6 +24,9 @@ type Keys struct {
 	dir Vec2
 	lastKeys map[KeyType]bool
 	lastKeyChange map[KeyType]SeqNumType
+
+	// Latest input applied, echoed to the client for reconciliation
+	seqNum SeqNumType
 }
 
 func NewKeys() Keys {
@@ -34,6 +37,7 @@ func NewKeys() Keys {
 		dir: NewVec2(0, 0),
 		lastKeys: make(map[KeyType]bool),
 		lastKeyChange: make(map[KeyType]SeqNumType),
+		seqNum: 0,
 	}
 }
 
@@ -68,6 +72,10 @@ func (k Keys) KeyPressed(key KeyType) bool {
 	return pressed
 }
 
+func (k Keys) SeqNum() SeqNumType {
+	return k.seqNum
+}
+
 func (k Keys) Mouse() Vec2 {
 	return k.mouse
 }
@@ -96,6 +104,15 @@ func (k *Keys) UpdateKeys(keyMsg KeyMsg) {
 
 	k.dir = keyMsg.D
 	k.mouse = keyMsg.M
+	if seqNum > k.seqNum {
+		k.seqNum = seqNum
+	}
+}
+
+// A reconnected client counts inputs from scratch, so forget the old client's sequence numbers.
+func (k *Keys) ResetSeqNum() {
+	k.seqNum = 0
+	k.lastKeyChange = make(map[KeyType]SeqNumType)
 }
 
 func (k *Keys) SetKeys(keys map[KeyType]bool) {
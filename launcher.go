@@ -111,7 +111,7 @@ func (l *Launcher) Reload() { l.ammo = l.maxAmmo }
 
 func (l *Launcher) Update(grid *Grid, now time.Time) {
 	if l.ammo > 0 && (l.pressed || l.ammo < l.maxAmmo) {
-		if l.ammoTimer.On() {
+		if l.ammoTimer.On(now) {
 			l.state = rechargingPartState
 			return
 		}
@@ -141,7 +141,7 @@ func (l *Launcher) Update(grid *Grid, now time.Time) {
 			return
 		}
 	} else if l.ammo == 0 {
-		if l.reloadTimer.On() {
+		if l.reloadTimer.On(now) {
 			l.state = rechargingPartState
 			return
 		} else {
@@ -173,8 +173,8 @@ func (l *Launcher) Shoot(grid *Grid, now time.Time) {
 	if charged {
 		l.ammo = 0
 	}
-	l.ammoTimer.Start()
-	l.reloadTimer.Start()
+	l.ammoTimer.Start(now)
+	l.reloadTimer.Start(now)
 
 	if isWasm {
 		return
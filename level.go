@@ -2,14 +2,15 @@ package main
 
 import (
 	"fmt"
-	"math/rand"
 )
 
+// Other levels only need an id in their file, see levels/
 type LevelIdType uint8
 const (
 	unknownLevel LevelIdType = iota
 	lobbyLevel
 	birdTownLevel
+	arenaLevel
 )
 type LevelSeedType uint32
 
@@ -34,21 +35,30 @@ func (l Level) GetSeed() LevelSeedType {
 	return l.seed
 }
 
+// The server may swap the seed for one that passes validation, so read it back with GetSeed.
 func (l *Level) LoadLevel(id LevelIdType, seed LevelSeedType, grid *Grid) {
-	l.id = id
-	l.seed = seed
-	l.Clear(grid)
-
-	switch id {
-	case lobbyLevel:
-		l.loadLobby(grid)
-	case birdTownLevel:
-		l.loadBirdTown(seed, grid)
-	default:
+	file, ok := levelFiles[id]
+	if !ok {
 		Log(fmt.Sprintf("Unknown map: %d", id))
+		l.id = id
+		l.seed = seed
+		l.Clear(grid)
 		return
 	}
 
+	// Clients load whatever seed the server settled on
+	if !isWasm {
+		seed = file.ValidSeed(seed)
+	}
+	l.load(file, seed, grid)
+}
+
+func (l *Level) load(file *LevelFile, seed LevelSeedType, grid *Grid) {
+	l.id = file.Id
+	l.seed = seed
+	l.Clear(grid)
+
+	file.Build(seed, &l.blockGrid)
 	l.blockGrid.UpsertToGrid(grid)
 }
 
@@ -61,188 +71,5 @@ func (l *Level) Clear(grid *Grid) {
 		}
 		grid.HardDelete(object.GetSpacedId())
 	}
+	grid.ClearHistory()
 }
-
-func (l *Level) loadLobby(grid *Grid) {
-	var b *MainBlock
-	var r *RoofBlock
-
-	{
-		building := l.blockGrid.AddBuilding(BuildingAttributes{
-			gap: 0,
-			blockType: archBlock,
-			color: archRed,
-			secondaryColor: archWhite,
-			height: 3,
-		})
-
-		b = building.GetBlock(2)
-		b.AddOpenings(leftCardinal, rightCardinal)
-		b.AddBalcony(NewVec2(-1, 0))
-		r = building.GetRoof()
-		r.AddOpenings(rightCardinal)
-
-		pos := b.PosC(bottomCardinal)
-		portal := NewPortal(NewInitC(
-			Id(portalSpace, 0),
-			NewVec2(pos.X, pos.Y + b.GetThickness()),
-			NewVec2(b.Dim().X / 2, 2),
-			bottomCardinal))
-		portal.SetFloatAttribute(dimZFloatAttribute, blockDimZs[archBlock] / 2)
-		portal.SetTeam(1)
-		b.AddObject(portal)
-	}
-
-	{
-		building := l.blockGrid.AddBuilding(BuildingAttributes{
-			gap: 0,
-			blockType: archBlock,
-			color: archGray,
-			secondaryColor: archWhite,
-			height: 4,
-		})
-
-		b = building.GetBlock(2)
-		b.AddOpenings(leftCardinal, rightCardinal)
-		b.LoadTemplate(weaponsBlockTemplate)
-
-		spawn := NewSpawn(NewInit(
-			Id(spawnSpace, 0),
-			b.Pos(),
-			NewVec2(6, 1),
-		))
-		spawn.SetByteAttribute(teamByteAttribute, 0)
-		b.AddObject(spawn)
-
-		b = building.GetBlock(3)
-		b.AddOpenings(leftCardinal, rightCardinal)
-	}
-
-	{
-		building := l.blockGrid.AddBuilding(BuildingAttributes{
-			gap: 0,
-			blockType: archBlock,
-			color: archBlue,
-			secondaryColor: archWhite,
-			height: 3,
-		})
-
-		b = building.GetBlock(2)
-		b.AddOpenings(leftCardinal, rightCardinal)
-		b.AddBalcony(NewVec2(1, 0))
-		r = building.GetRoof()
-		r.AddOpenings(leftCardinal)
-
-		pos := b.PosC(bottomCardinal)
-		portal := NewPortal(NewInitC(
-			Id(portalSpace, 0),
-			NewVec2(pos.X, pos.Y + b.GetThickness()),
-			NewVec2(b.Dim().X / 2, 2),
-			bottomCardinal))
-		portal.SetFloatAttribute(dimZFloatAttribute, blockDimZs[archBlock] / 2)
-		portal.SetTeam(2)
-		b.AddObject(portal)
-	}
-}
-
-func (l *Level) loadBirdTown(seed LevelSeedType, grid *Grid) {
-	colors := [...]int {archRed, archOrange, archYellow, archGreen, archBlue, archPurple}
-	r := rand.New(rand.NewSource(int64(seed)))
-	r.Shuffle(len(colors), func(i, j int) { colors[i], colors[j] = colors[j], colors[i] })
-
-	var b *MainBlock
-
-	defaultGap := 9.0
-	gapChance := NewGrowingChance(r, 15, 15)
-
-	currentHeight := 1
-	heightChance := NewGrowingChance(r, 50, 30)
-
-	growChance := NewGrowingChance(r, 70, 20)
-
-	numBuildings := 7 + r.Intn(3)
-
-	l.blockGrid.SetYOffsets(2, -1)
-
-	for i := 0; i < numBuildings; i += 1 {
-		gap := 0.0
-		if i == 0 {
-			gap = 20
-		} else if gapChance.Roll() {
-			gap = defaultGap
-		} else if heightChance.Roll() {
-			currentHeight += 1
-		}
-
-		color := colors[i % len(colors)]
-		height := currentHeight
-		if growChance.Roll() {
-			height += 1
-		}
-
-		building := l.blockGrid.AddBuilding(BuildingAttributes{
-			gap: gap,
-			blockType: archBlock,
-			color: color,
-			secondaryColor: archWhite,
-			height: height,
-		})
-
-		for j := IntMax(1, height - 2); j < height; j += 1 {
-			b = building.GetBlock(j)
-
-			if i > 0 {
-				b.AddOpenings(leftCardinal)
-			}
-			b.AddOpenings(rightCardinal)
-		}
-
-		if i == 0 {
-			roof := building.GetRoof()
-			roof.LoadTemplate(weaponsBlockTemplate)
-			spawn := NewSpawn(NewInit(
-				Id(spawnSpace, 0),
-				NewVec2(roof.Pos().X, roof.Pos().Y + 2),
-				NewVec2(6, 1),
-			))
-			spawn.SetByteAttribute(teamByteAttribute, 1)
-			spawn.SetInitDir(NewVec2(1, 0))
-			roof.AddObject(spawn)
-		}
-
-		if i == numBuildings - 1 {
-			roof := building.GetRoof()
-			pos := roof.PosC(bottomCardinal)
-			goal := NewGoal(NewInitC(
-				Id(goalSpace, 0),
-				NewVec2(pos.X, pos.Y + roof.GetThickness()),
-				NewVec2(roof.Dim().X / 2, 2),
-				bottomCardinal))
-			goal.SetFloatAttribute(dimZFloatAttribute, blockDimZs[archBlock] / 2)
-			goal.SetTeam(1)
-			roof.AddObject(goal)
-
-			building := l.blockGrid.AddBuilding(BuildingAttributes{
-				gap: defaultGap / 2,
-				blockType: archBlock,
-				color: colors[numBuildings % len(colors)],
-				secondaryColor: archWhite,
-				height: height,
-			})
-			backRoof := building.GetRoof()
-			backRoof.LoadTemplate(weaponsBlockTemplate)
-
-			spawn := NewSpawn(NewInit(
-				Id(spawnSpace, 0),
-				NewVec2(backRoof.Pos().X, backRoof.Pos().Y + 2),
-				NewVec2(6, 1),
-			))
-			spawn.SetByteAttribute(teamByteAttribute, 2)
-			spawn.SetInitDir(NewVec2(-1, 0))
-			backRoof.AddObject(spawn)
-		}
-	}
-
-	l.blockGrid.Connect(r)
-	l.blockGrid.Randomize(r)
-}
\ No newline at end of file
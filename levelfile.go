@@ -0,0 +1,650 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	levelDir string = "levels"
+
+	defaultLevelRerolls int = 8
+	defaultLevelMaxAsymmetry float64 = 2.0
+)
+
+// Level files are compiled into the binary so the server and WASM always agree on them.
+//go:embed levels/*.json
+var levelFS embed.FS
+
+var levelFiles map[LevelIdType]*LevelFile
+
+// Layouts that can't be described building by building. They get the file's palette already shuffled.
+type levelGenerator func(lf LevelFile, r *rand.Rand, palette []int, bg *BlockGrid)
+var levelGenerators = map[string]levelGenerator {
+	arenaGenerator: buildArena,
+}
+
+// Loaded in init so the name maps used while parsing are already set.
+func init() {
+	levelFiles = mustLoadLevelFiles()
+}
+
+var cardinalNames = map[string]int {
+	"left": int(leftCardinal),
+	"right": int(rightCardinal),
+	"bottom": int(bottomCardinal),
+	"top": int(topCardinal),
+	"bottomLeft": int(bottomLeftCardinal),
+	"bottomRight": int(bottomRightCardinal),
+	"topLeft": int(topLeftCardinal),
+	"topRight": int(topRightCardinal),
+}
+
+var colorNames = map[string]int {
+	"red": archRed,
+	"orange": archOrange,
+	"yellow": archYellow,
+	"green": archGreen,
+	"blue": archBlue,
+	"purple": archPurple,
+	"white": archWhite,
+	"gray": archGray,
+}
+
+var blockTypeNames = map[string]int {
+	"arch": int(archBlock),
+}
+
+var blockTemplateNames = map[string]int {
+	"weapons": int(weaponsBlockTemplate),
+	"table": int(tableBlockTemplate),
+	"crates": int(cratesBlockTemplate),
+	"jumpPad": int(jumpPadBlockTemplate),
+}
+
+var equipNames = map[string]int {
+	"uzi": int(uziWeapon),
+	"grapplingHook": int(grapplingHookWeapon),
+	"bazooka": int(bazookaWeapon),
+	"sniper": int(sniperWeapon),
+	"star": int(starWeapon),
+	"booster": int(boosterEquip),
+	"charger": int(chargerEquip),
+	"jetpack": int(jetpackEquip),
+}
+
+var lightNames = map[string]int {
+	"point": int(pointLight),
+	"spot": int(spotLight),
+	"floor": int(floorLight),
+}
+
+type LevelObjectType uint8
+const (
+	unknownLevelObject LevelObjectType = iota
+	spawnLevelObject
+	goalLevelObject
+	portalLevelObject
+	votePadsLevelObject
+	pickupLevelObject
+	lightLevelObject
+)
+
+var levelObjectNames = map[string]int {
+	"spawn": int(spawnLevelObject),
+	"goal": int(goalLevelObject),
+	"portal": int(portalLevelObject),
+	"votePads": int(votePadsLevelObject),
+	"pickup": int(pickupLevelObject),
+	"light": int(lightLevelObject),
+}
+
+type AnchorType uint8
+const (
+	unknownAnchor AnchorType = iota
+	floorAnchor
+	centerAnchor
+)
+
+var anchorNames = map[string]int {
+	"floor": int(floorAnchor),
+	"center": int(centerAnchor),
+}
+
+// Describes a map. Buildings are placed left to right, and anything random is rolled from the level seed.
+type LevelFile struct {
+	Id LevelIdType `json:"id"`
+	Name string `json:"name"`
+
+	YOffsets []float64 `json:"yOffsets"`
+	Palette []levelColor `json:"palette"`
+	ShufflePalette bool `json:"shufflePalette"`
+	StartHeight int `json:"startHeight"`
+	Chances map[string]LevelChance `json:"chances"`
+
+	Buildings []LevelBuilding `json:"buildings"`
+	// Lay out buildings in code instead, see levelGenerators
+	Generator string `json:"generator"`
+
+	// Add balconies, stairs and openings between neighboring buildings
+	Connect bool `json:"connect"`
+	// Sprinkle tables into open floors
+	Randomize bool `json:"randomize"`
+
+	// Reroll seeds that fail validation on the server
+	Validate *LevelValidation `json:"validate"`
+}
+
+type LevelValidation struct {
+	Rerolls int `json:"rerolls"`
+	// Largest allowed ratio between two teams' detours to a goal
+	MaxAsymmetry float64 `json:"maxAsymmetry"`
+}
+
+// Growing chance shared by every building that names it, see Chance
+type LevelChance struct {
+	Min int `json:"min"`
+	Increment int `json:"increment"`
+}
+
+type LevelBuilding struct {
+	// Number of copies as [min, max], defaults to one
+	Repeat []int `json:"repeat"`
+
+	Gap float64 `json:"gap"`
+	// If GapChance rolls the gap is ChanceGap, otherwise RaiseChance can raise the running height
+	GapChance string `json:"gapChance"`
+	ChanceGap float64 `json:"chanceGap"`
+	RaiseChance string `json:"raiseChance"`
+
+	// Fixed height, otherwise the running height or the previous building's height
+	Height int `json:"height"`
+	SameHeight bool `json:"sameHeight"`
+	// Makes this building one taller than the running height
+	GrowChance string `json:"growChance"`
+
+	BlockType levelBlockType `json:"blockType"`
+	// Defaults to the next palette color
+	Color *levelColor `json:"color"`
+	SecondaryColor *levelColor `json:"secondaryColor"`
+
+	Blocks []LevelBlock `json:"blocks"`
+	Roof *LevelRoof `json:"roof"`
+}
+
+type LevelBlock struct {
+	// Negative levels count down from the top block
+	Levels []int `json:"levels"`
+	AboveGround bool `json:"aboveGround"`
+
+	Openings []levelCardinal `json:"openings"`
+	Balcony *levelCardinal `json:"balcony"`
+	Template *levelTemplate `json:"template"`
+	Objects []LevelObject `json:"objects"`
+}
+
+type LevelRoof struct {
+	Openings []levelCardinal `json:"openings"`
+	Template *levelTemplate `json:"template"`
+	Hut bool `json:"hut"`
+	Objects []LevelObject `json:"objects"`
+}
+
+type LevelObject struct {
+	Type levelObjectType `json:"type"`
+	Anchor *levelAnchor `json:"anchor"`
+	Offset levelVec2 `json:"offset"`
+	Dim *levelVec2 `json:"dim"`
+	Dir *levelVec2 `json:"dir"`
+
+	Team uint8 `json:"team"`
+	Weapon levelEquip `json:"weapon"`
+	Subtype levelEquip `json:"subtype"`
+	Light levelLight `json:"light"`
+	Color *levelColor `json:"color"`
+}
+
+// Fills in defaults for anything the file leaves out.
+func (lv *LevelValidation) UnmarshalJSON(b []byte) error {
+	type plainValidation LevelValidation
+	validation := plainValidation {
+		Rerolls: defaultLevelRerolls,
+		MaxAsymmetry: defaultLevelMaxAsymmetry,
+	}
+	if err := json.Unmarshal(b, &validation); err != nil {
+		return err
+	}
+	*lv = LevelValidation(validation)
+	return nil
+}
+
+type levelCardinal CardinalType
+type levelColor int
+type levelBlockType BlockType
+type levelTemplate BlockTemplate
+type levelEquip EquipType
+type levelLight uint8
+type levelObjectType LevelObjectType
+type levelAnchor AnchorType
+type levelVec2 Vec2
+
+func (lc *levelCardinal) UnmarshalJSON(b []byte) error {
+	value, err := unmarshalName(b, "cardinal", cardinalNames)
+	*lc = levelCardinal(value)
+	return err
+}
+
+// Colors are names or hex numbers
+func (lc *levelColor) UnmarshalJSON(b []byte) error {
+	var number int
+	if err := json.Unmarshal(b, &number); err == nil {
+		*lc = levelColor(number)
+		return nil
+	}
+	value, err := unmarshalName(b, "color", colorNames)
+	*lc = levelColor(value)
+	return err
+}
+
+func (lbt *levelBlockType) UnmarshalJSON(b []byte) error {
+	value, err := unmarshalName(b, "block type", blockTypeNames)
+	*lbt = levelBlockType(value)
+	return err
+}
+
+func (lt *levelTemplate) UnmarshalJSON(b []byte) error {
+	value, err := unmarshalName(b, "template", blockTemplateNames)
+	*lt = levelTemplate(value)
+	return err
+}
+
+func (le *levelEquip) UnmarshalJSON(b []byte) error {
+	value, err := unmarshalName(b, "equip", equipNames)
+	*le = levelEquip(value)
+	return err
+}
+
+func (ll *levelLight) UnmarshalJSON(b []byte) error {
+	value, err := unmarshalName(b, "light", lightNames)
+	*ll = levelLight(value)
+	return err
+}
+
+func (lot *levelObjectType) UnmarshalJSON(b []byte) error {
+	value, err := unmarshalName(b, "object type", levelObjectNames)
+	*lot = levelObjectType(value)
+	return err
+}
+
+func (la *levelAnchor) UnmarshalJSON(b []byte) error {
+	value, err := unmarshalName(b, "anchor", anchorNames)
+	*la = levelAnchor(value)
+	return err
+}
+
+func (lv *levelVec2) UnmarshalJSON(b []byte) error {
+	var values []float64
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+	if len(values) != 2 {
+		return fmt.Errorf("expected [x, y], got %s", string(b))
+	}
+	*lv = levelVec2(NewVec2(values[0], values[1]))
+	return nil
+}
+
+func unmarshalName(b []byte, kind string, names map[string]int) (int, error) {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return 0, err
+	}
+	value, ok := names[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown %s %q", kind, name)
+	}
+	return value, nil
+}
+
+func ParseLevelFile(b []byte) (*LevelFile, error) {
+	file := &LevelFile {
+		StartHeight: 1,
+	}
+	if err := json.Unmarshal(b, file); err != nil {
+		return nil, err
+	}
+	if file.Id == unknownLevel {
+		return nil, fmt.Errorf("level %q is missing an id", file.Name)
+	}
+	if _, ok := levelGenerators[file.Generator]; file.Generator != "" && !ok {
+		return nil, fmt.Errorf("level %q has unknown generator %q", file.Name, file.Generator)
+	}
+	if file.Generator != "" && len(file.Palette) == 0 {
+		return nil, fmt.Errorf("level %q needs a palette for its generator", file.Name)
+	}
+	if len(file.Buildings) == 0 && file.Generator == "" {
+		return nil, fmt.Errorf("level %q has no buildings", file.Name)
+	}
+
+	for i, building := range(file.Buildings) {
+		if len(building.Repeat) != 0 && (len(building.Repeat) != 2 || building.Repeat[0] < 0 || building.Repeat[1] < building.Repeat[0]) {
+			return nil, fmt.Errorf("building %d: repeat should be [min, max]", i)
+		}
+		if building.Color == nil && len(file.Palette) == 0 {
+			return nil, fmt.Errorf("building %d: no color and no palette", i)
+		}
+		for _, name := range([]string{building.GapChance, building.RaiseChance, building.GrowChance}) {
+			if _, ok := file.Chances[name]; name != "" && !ok {
+				return nil, fmt.Errorf("building %d: unknown chance %q", i, name)
+			}
+		}
+	}
+
+	if file.Validate != nil && (file.Validate.Rerolls < 0 || file.Validate.MaxAsymmetry < 1) {
+		return nil, fmt.Errorf("level %q: validate needs rerolls >= 0 and maxAsymmetry >= 1", file.Name)
+	}
+	return file, nil
+}
+
+// Embedded levels are part of the build, so a bad one is a programming error.
+func mustLoadLevelFiles() map[LevelIdType]*LevelFile {
+	entries, err := levelFS.ReadDir(levelDir)
+	if err != nil {
+		panic(err)
+	}
+
+	files := make(map[LevelIdType]*LevelFile)
+	for _, entry := range(entries) {
+		b, err := levelFS.ReadFile(path.Join(levelDir, entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		file, err := ParseLevelFile(b)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to parse %s: %v", entry.Name(), err))
+		}
+		if existing, ok := files[file.Id]; ok {
+			panic(fmt.Sprintf("%s reuses level id %d from %s", entry.Name(), file.Id, existing.Name))
+		}
+		files[file.Id] = file
+	}
+	return files
+}
+
+func LevelByName(name string) (LevelIdType, bool) {
+	for id, file := range(levelFiles) {
+		if strings.EqualFold(file.Name, name) {
+			return id, true
+		}
+	}
+	return unknownLevel, false
+}
+
+// Levels that can be played outside the lobby, in id order
+func MatchLevelNames() []string {
+	ids := make([]int, 0, len(levelFiles))
+	for id := range(levelFiles) {
+		if id != lobbyLevel {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+
+	names := make([]string, len(ids))
+	for i, id := range(ids) {
+		names[i] = levelFiles[LevelIdType(id)].Name
+	}
+	return names
+}
+
+func LevelName(id LevelIdType) string {
+	file, ok := levelFiles[id]
+	if !ok {
+		return "unknown"
+	}
+	return file.Name
+}
+
+// Lays out every building from the file in the block grid.
+func (lf LevelFile) Build(seed LevelSeedType, bg *BlockGrid) {
+	r := rand.New(rand.NewSource(int64(seed)))
+	palette := lf.palette(r)
+
+	if lf.Generator != "" {
+		levelGenerators[lf.Generator](lf, r, palette, bg)
+		return
+	}
+
+	counts := make([]int, len(lf.Buildings))
+	for i, building := range(lf.Buildings) {
+		counts[i] = 1
+		if len(building.Repeat) == 2 {
+			counts[i] = building.Repeat[0] + r.Intn(building.Repeat[1] - building.Repeat[0] + 1)
+		}
+	}
+
+	chances := make(map[string]*Chance, len(lf.Chances))
+	for name, chance := range(lf.Chances) {
+		growing := NewGrowingChance(r, chance.Min, chance.Increment)
+		chances[name] = &growing
+	}
+	roll := func(name string) bool {
+		return name != "" && chances[name].Roll()
+	}
+
+	if len(lf.YOffsets) > 0 {
+		bg.SetYOffsets(lf.YOffsets...)
+	}
+
+	currentHeight := lf.StartHeight
+	lastHeight := currentHeight
+	index := 0
+	for i, spec := range(lf.Buildings) {
+		for k := 0; k < counts[i]; k += 1 {
+			gap := spec.Gap
+			if roll(spec.GapChance) {
+				gap = spec.ChanceGap
+			} else if roll(spec.RaiseChance) {
+				currentHeight += 1
+			}
+
+			height := currentHeight
+			if spec.Height > 0 {
+				height = spec.Height
+			} else if spec.SameHeight {
+				height = lastHeight
+			}
+			if roll(spec.GrowChance) {
+				height += 1
+			}
+
+			blockType := archBlock
+			if spec.BlockType != levelBlockType(unknownBlock) {
+				blockType = BlockType(spec.BlockType)
+			}
+			var color int
+			if spec.Color != nil {
+				color = int(*spec.Color)
+			} else {
+				color = palette[index % len(palette)]
+			}
+			secondaryColor := archWhite
+			if spec.SecondaryColor != nil {
+				secondaryColor = int(*spec.SecondaryColor)
+			}
+
+			building := bg.AddBuilding(BuildingAttributes{
+				gap: gap,
+				blockType: blockType,
+				color: color,
+				secondaryColor: secondaryColor,
+				height: height,
+			})
+
+			for _, blockSpec := range(spec.Blocks) {
+				for _, level := range(blockSpec.Levels) {
+					if level < 0 {
+						level += height
+					}
+					if level < 0 || level >= height || (blockSpec.AboveGround && level == 0) {
+						continue
+					}
+					blockSpec.apply(building.GetBlock(level))
+				}
+			}
+			if spec.Roof != nil {
+				spec.Roof.apply(building.GetRoof())
+			}
+
+			lastHeight = height
+			index += 1
+		}
+	}
+
+	if lf.Connect {
+		bg.Connect(r)
+	}
+	if lf.Randomize {
+		bg.Randomize(r)
+	}
+}
+
+func (lf LevelFile) palette(r *rand.Rand) []int {
+	palette := make([]int, len(lf.Palette))
+	for i, color := range(lf.Palette) {
+		palette[i] = int(color)
+	}
+	if lf.ShufflePalette {
+		r.Shuffle(len(palette), func(i, j int) { palette[i], palette[j] = palette[j], palette[i] })
+	}
+	return palette
+}
+
+func (lb LevelBlock) apply(b *MainBlock) {
+	b.AddOpenings(toCardinals(lb.Openings)...)
+	if lb.Balcony != nil {
+		if CardinalType(*lb.Balcony) == leftCardinal {
+			b.AddBalcony(NewVec2(-1, 0))
+		} else {
+			b.AddBalcony(NewVec2(1, 0))
+		}
+	}
+	if lb.Template != nil {
+		b.LoadTemplate(BlockTemplate(*lb.Template))
+	}
+	for _, object := range(lb.Objects) {
+		object.addTo(b)
+	}
+}
+
+func (lr LevelRoof) apply(r *RoofBlock) {
+	r.AddOpenings(toCardinals(lr.Openings)...)
+	if lr.Template != nil {
+		r.LoadTemplate(BlockTemplate(*lr.Template))
+	}
+	if lr.Hut {
+		r.AddHut()
+	}
+	for _, object := range(lr.Objects) {
+		object.addTo(r)
+	}
+}
+
+func toCardinals(names []levelCardinal) []CardinalType {
+	cardinals := make([]CardinalType, len(names))
+	for i, name := range(names) {
+		cardinals[i] = CardinalType(name)
+	}
+	return cardinals
+}
+
+// Returns where the object goes in the block and which side of it that point is on.
+func (lo LevelObject) anchor(b Block, defaultAnchor AnchorType) (Vec2, CardinalType) {
+	anchor := defaultAnchor
+	if lo.Anchor != nil {
+		anchor = AnchorType(*lo.Anchor)
+	}
+
+	var pos Vec2
+	cardinal := unknownCardinal
+	if anchor == floorAnchor {
+		pos = b.PosC(bottomCardinal)
+		pos.Y += b.GetThickness()
+		cardinal = bottomCardinal
+	} else {
+		pos = b.Pos()
+	}
+	pos.Add(Vec2(lo.Offset), 1.0)
+	return pos, cardinal
+}
+
+func (lo LevelObject) dim(defaultDim Vec2) Vec2 {
+	if lo.Dim != nil {
+		return Vec2(*lo.Dim)
+	}
+	return defaultDim
+}
+
+func (lo LevelObject) addTo(b Block) {
+	dimZ := blockDimZs[b.GetBlockType()] / 2
+
+	switch LevelObjectType(lo.Type) {
+	case spawnLevelObject:
+		pos, cardinal := lo.anchor(b, centerAnchor)
+		spawn := NewSpawn(NewInitC(Id(spawnSpace, 0), pos, lo.dim(NewVec2(6, 1)), cardinal))
+		spawn.SetByteAttribute(teamByteAttribute, lo.Team)
+		if lo.Dir != nil {
+			spawn.SetInitDir(Vec2(*lo.Dir))
+		}
+		b.AddObject(spawn)
+	case goalLevelObject:
+		pos, cardinal := lo.anchor(b, floorAnchor)
+		goal := NewGoal(NewInitC(Id(goalSpace, 0), pos, lo.dim(NewVec2(b.Dim().X / 2, 2)), cardinal))
+		goal.SetFloatAttribute(dimZFloatAttribute, dimZ)
+		goal.SetTeam(lo.Team)
+		b.AddObject(goal)
+	case portalLevelObject:
+		pos, cardinal := lo.anchor(b, floorAnchor)
+		portal := NewPortal(NewInitC(Id(portalSpace, 0), pos, lo.dim(NewVec2(b.Dim().X / 2, 2)), cardinal))
+		portal.SetFloatAttribute(dimZFloatAttribute, dimZ)
+		portal.SetTeam(lo.Team)
+		b.AddObject(portal)
+	case votePadsLevelObject:
+		// One pad for each game mode, spread evenly across the floor
+		modes := GameModeIds()
+		pos := b.PosC(bottomLeftCardinal)
+		width := b.Dim().X / float64(len(modes) + 1)
+		for i, mode := range(modes) {
+			pad := NewPortal(NewInitC(
+				Id(portalSpace, 0),
+				NewVec2(pos.X + float64(i + 1) * width, pos.Y + b.GetThickness()),
+				NewVec2(width / 2, 1),
+				bottomCardinal))
+			pad.SetFloatAttribute(dimZFloatAttribute, dimZ)
+			pad.SetMode(mode)
+			b.AddObject(pad)
+		}
+	case pickupLevelObject:
+		pos, cardinal := lo.anchor(b, floorAnchor)
+		pickup := NewPickup(NewInitC(Id(pickupSpace, 0), pos, lo.dim(NewVec2(1.2, 1.2)), cardinal))
+		pickup.SetByteAttribute(typeByteAttribute, uint8(lo.Weapon))
+		pickup.SetByteAttribute(subtypeByteAttribute, uint8(lo.Subtype))
+		b.AddObject(pickup)
+	case lightLevelObject:
+		pos, cardinal := lo.anchor(b, centerAnchor)
+		light := NewLight(NewInitC(Id(lightSpace, 0), pos, lo.dim(NewVec2(1, 1)), cardinal))
+		light.SetByteAttribute(typeByteAttribute, uint8(lo.Light))
+		color := archWhite
+		if lo.Color != nil {
+			color = int(*lo.Color)
+		}
+		light.SetIntAttribute(colorIntAttribute, color)
+		b.AddObject(light)
+	}
+}
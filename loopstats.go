@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Measures how much of the time a room spends handling events and how far ticks drift from frameTime.
+type LoopStats struct {
+	start time.Time
+	busy time.Duration
+
+	lastTick time.Time
+	ticks int
+	jitter time.Duration
+	maxJitter time.Duration
+}
+
+func NewLoopStats() LoopStats {
+	return LoopStats {
+		start: time.Now(),
+	}
+}
+
+func (ls *LoopStats) AddBusy(d time.Duration) {
+	ls.busy += d
+}
+
+func (ls *LoopStats) Tick(now time.Time) {
+	if !ls.lastTick.IsZero() {
+		jitter := now.Sub(ls.lastTick) - frameTime
+		if jitter < 0 {
+			jitter = -jitter
+		}
+		ls.jitter += jitter
+		if jitter > ls.maxJitter {
+			ls.maxJitter = jitter
+		}
+		ls.ticks += 1
+	}
+	ls.lastTick = now
+}
+
+// Fraction of wall time spent handling events, where 1 is a full core.
+func (ls LoopStats) Load() float64 {
+	elapsed := time.Since(ls.start)
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(ls.busy) / float64(elapsed)
+}
+
+func (ls LoopStats) AvgJitter() time.Duration {
+	if ls.ticks == 0 {
+		return 0
+	}
+	return ls.jitter / time.Duration(ls.ticks)
+}
+
+func (ls LoopStats) MaxJitter() time.Duration {
+	return ls.maxJitter
+}
+
+// Keeps the last tick so jitter is measured across resets.
+func (ls *LoopStats) Reset() {
+	ls.start = time.Now()
+	ls.busy = 0
+	ls.ticks = 0
+	ls.jitter = 0
+	ls.maxJitter = 0
+}
+
+func (ls LoopStats) String() string {
+	return fmt.Sprintf("load %.1f%%, tick jitter avg %v max %v", 100 * ls.Load(), ls.AvgJitter(), ls.MaxJitter())
+}
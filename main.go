@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 const (
 	clientEndpoint string = "/bd3/"
+
+	// How long to wait for rooms and requests to finish on shutdown
+	shutdownTimeout time.Duration = 10 * time.Second
+
+	// Run as "server validate -level 2 -seed 100 -count 50" to check levels instead of serving
+	validateCommand string = "validate"
 )
 
 var allowedOrigins = map[string]bool {
@@ -32,7 +43,13 @@ var upgrader = websocket.Upgrader{
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == validateCommand {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+
 	http.HandleFunc(clientEndpoint, clientEndpointHandler)
+	http.HandleFunc(roomsEndpoint, roomsEndpointHandler)
+	http.HandleFunc(statusEndpoint, statusEndpointHandler)
 
 	// TODO: remove this eventually
 	serveFiles("/")
@@ -43,10 +60,64 @@ func main() {
 		log.Printf("Defaulting to port %s", port)
 	}
 
-	log.Printf("Listening on port %s", port)
-	if err := http.ListenAndServe(":" + port, nil); err != nil {
-		log.Fatal(err)
+	server := &http.Server{Addr: ":" + port}
+	go func() {
+		log.Printf("Listening on port %s", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	log.Printf("Received %v, shutting down", sig)
+
+	// Websockets are hijacked so the server doesn't track them, rooms close those
+	if !roomManager.Shutdown(shutdownTimeout) {
+		log.Printf("Timed out waiting for rooms to shut down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	log.Printf("Shut down")
+}
+
+// Prints a report for each seed and returns the exit code.
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet(validateCommand, flag.ExitOnError)
+	level := flags.Uint("level", uint(birdTownLevel), "level id")
+	seed := flags.Uint("seed", 0, "first seed to check")
+	count := flags.Uint("count", 1, "number of consecutive seeds to check")
+	verbose := flags.Bool("v", false, "print every path")
+	flags.Parse(args)
+
+	failed := 0
+	for i := uint(0); i < *count; i += 1 {
+		report, err := ValidateLevel(LevelIdType(*level), LevelSeedType(*seed + i))
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+
+		if !report.Ok() {
+			failed += 1
+		}
+		if *verbose {
+			fmt.Println(report.String())
+		} else {
+			fmt.Println(report.Summary())
+		}
+	}
+
+	fmt.Printf("%d of %d seeds passed\n", *count - uint(failed), *count)
+	if failed > 0 {
+		return 1
 	}
+	return 0
 }
 
 func clientEndpointHandler(w http.ResponseWriter, r *http.Request) {
@@ -91,13 +162,59 @@ func clientEndpointHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if token, ok := vars["token"]; ok && len(token) > maxReconnectTokenLength {
+		log.Printf("Reconnect token should be at most %d chars long", maxReconnectTokenLength)
+		return
+	}
+
+	if spectate, ok := vars["spectate"]; ok && spectate != "0" && spectate != "1" {
+		log.Printf("Invalid spectate option: %s", spectate)
+		return
+	}
+
+	if max, ok := vars["max"]; ok {
+		maxInt, err := strconv.Atoi(max)
+		if err != nil || maxInt < 1 || maxInt > maxRoomPlayers {
+			log.Printf("Max players should be 1-%d: %s", maxRoomPlayers, max)
+			return
+		}
+	}
+
+	if password, ok := vars["password"]; ok && len(password) > maxPasswordLength {
+		log.Printf("Password should be at most %d chars long", maxPasswordLength)
+		return
+	}
+
+	if private, ok := vars["private"]; ok && private != "0" && private != "1" {
+		log.Printf("Invalid private option: %s", private)
+		return
+	}
+
+	if replay, ok := vars["replay"]; ok && !ValidReplayName(replay) {
+		log.Printf("Replay should be a %s file with at most %d chars: %s", replayExtension, maxReplayNameLength, replay)
+		return
+	}
+
+	if codec, ok := vars["codec"]; ok {
+		if _, codecOk := codecNames[codec]; !codecOk {
+			log.Printf("Invalid codec: %s", codec)
+			return
+		}
+	}
+
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Failed to create websocket: %v", err)
 		return
 	}
-	
+
 	// Try to keep the socket alive?
 	ws.SetReadDeadline(time.Time{})
-	CreateOrJoinRoom(vars, ws)
-}
\ No newline at end of file
+	roomManager.CreateOrJoin(vars, ws)
+}
+
+func closeWithReason(ws *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	ws.Close()
+}
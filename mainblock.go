@@ -1,5 +1,9 @@
 package main
 
+const (
+	elevatorSpeed float64 = 2.0
+)
+
 type MainBlock struct {
 	BaseBlock
 }
@@ -77,6 +81,21 @@ func (mb *MainBlock) LoadTemplate(template BlockTemplate) {
 		mb.objects = append(mb.objects, table)
 
 		mb.occupied.Add(bottomCardinal)
+
+	case cratesBlockTemplate:
+		if mb.occupied.Any(bottomLeftCardinal, bottomRightCardinal) || mb.AnyOpenings(bottomCardinal, bottomLeftCardinal, bottomRightCardinal) {
+			break
+		}
+
+		// Low enough to jump over, tall enough to duck behind
+		for _, offset := range([]float64{-width / 4, width / 4}) {
+			crate := NewWall(NewInitC(Id(wallSpace, 0), NewVec2(x + offset, y + mb.thick), NewVec2(1.5, 1.5), bottomCardinal))
+			crate.AddAttribute(visibleAttribute)
+			crate.SetIntAttribute(colorIntAttribute, crateColor)
+			mb.objects = append(mb.objects, crate)
+		}
+
+		mb.occupied.AddAll(bottomLeftCardinal, bottomRightCardinal)
 	}
 }
 
@@ -131,6 +150,69 @@ func (mb *MainBlock) LoadSidedTemplate(template SidedBlockTemplate, cardinal Car
 
 			mb.occupied.AddAll(bottomLeftCardinal, bottomCardinal, bottomRightCardinal)
 		}
+
+	case ladderSidedBlockTemplate:
+		// Platforms you can jump up through, ending below a hole in the block above
+		numRungs := 3.0
+		rungSpacing := baseHeight / (numRungs + 1)
+		for i := 1.0; i <= numRungs; i += 1 {
+			rung := NewWall(NewInitC(Id(wallSpace, 0),
+				NewVec2(x + dir * (width / 2 - mb.thick), y + mb.thick + i * rungSpacing),
+				NewVec2(3, mb.thick), origin))
+			rung.SetByteAttribute(typeByteAttribute, uint8(platformWall))
+			rung.AddAttribute(visibleAttribute)
+			rung.SetFloatAttribute(dimZFloatAttribute, innerDimZ / 2)
+			if color, ok := mb.GetIntAttribute(secondaryColorIntAttribute); ok {
+				rung.SetIntAttribute(colorIntAttribute, color)
+			}
+			mb.objects = append(mb.objects, rung)
+		}
+
+		mb.occupied.Add(origin)
+
+	case sniperNestSidedBlockTemplate:
+		// Ledge by the opening with a sniper on it, low enough to still see out
+		ledgeHeight := baseHeight * mb.sideOpening / 2
+		ledge := NewWall(NewInitC(Id(wallSpace, 0),
+			NewVec2(x + dir * (width / 2 - mb.thick), y + mb.thick + ledgeHeight),
+			NewVec2(3, mb.thick), origin))
+		ledge.SetByteAttribute(typeByteAttribute, uint8(platformWall))
+		ledge.AddAttribute(visibleAttribute)
+		ledge.SetFloatAttribute(dimZFloatAttribute, innerDimZ / 2)
+		if color, ok := mb.GetIntAttribute(secondaryColorIntAttribute); ok {
+			ledge.SetIntAttribute(colorIntAttribute, color)
+		}
+		mb.objects = append(mb.objects, ledge)
+
+		sniper := NewPickup(NewInitC(Id(pickupSpace, 0),
+			NewVec2(x + dir * (width / 2 - mb.thick - 1.5), y + 2 * mb.thick + ledgeHeight),
+			NewVec2(1.2, 1.2), bottomCardinal))
+		sniper.SetByteAttribute(typeByteAttribute, uint8(sniperWeapon))
+		sniper.SetByteAttribute(subtypeByteAttribute, uint8(chargerEquip))
+		mb.objects = append(mb.objects, sniper)
+
+		mb.occupied.Add(origin)
+
+	case elevatorSidedBlockTemplate:
+		// Rides between this floor and a hole in the block above. Players can jump up through it,
+		// so it never pins anyone against the floor on the way down.
+		bottom := NewVec2(x + dir * (width / 2 - mb.thick - 1.5), y + mb.thick / 2)
+		top := bottom
+		top.Y += baseHeight
+
+		elevator := NewWall(NewInit(Id(wallSpace, 0), bottom, NewVec2(3, mb.thick)))
+		elevator.SetByteAttribute(typeByteAttribute, uint8(platformWall))
+		elevator.AddAttribute(visibleAttribute)
+		elevator.SetFloatAttribute(dimZFloatAttribute, innerDimZ / 2)
+		if color, ok := mb.GetIntAttribute(secondaryColorIntAttribute); ok {
+			elevator.SetIntAttribute(colorIntAttribute, color)
+		}
+		elevator.SetSpeed(elevatorSpeed)
+		elevator.AddWaypoint(bottom)
+		elevator.AddWaypoint(top)
+		mb.objects = append(mb.objects, elevator)
+
+		mb.occupied.Add(origin)
 	}
 }
 
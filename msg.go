@@ -10,6 +10,8 @@ type Msg struct {
 	Key KeyMsg
 	Join ClientMsg
 	Left ClientMsg
+	Spectate SpectateMsg
+	Ack AckMsg
 }
 
 type MessageType uint8
@@ -40,6 +42,11 @@ const (
 	objectUpdateType
 	playerInitType
 	levelInitType
+	spectateType
+	ackType
+	compactObjectDataType
+	shutdownType
+	serverPingType
 )
 
 type ShotPropMaps []PropMap
@@ -66,14 +73,21 @@ type JSONPeerMsg struct {
 type ClientData struct {
 	Id IdType
 	Name string
+	Spectator bool
 }
 
 type ClientMsg struct {
 	T MessageType
 	Client ClientData
 	Clients map[IdType]ClientData
+
+	// Only sent to the client itself so it can reclaim its ID later
+	Token string `msgpack:",omitempty"`
 }
 
+// Sender ID for chat messages from the server
+const systemChatId IdType = 0xFFFF
+
 type ChatMsg struct {
 	T MessageType
 	Id IdType
@@ -88,9 +102,20 @@ type GameStateMsg struct {
 type ObjectStateMsg struct {
 	T MessageType
 	S SeqNumType
+	B SeqNumType // baseline for object data, 0 if it's a full snapshot
 	Os ObjectPropMap
 }
 
+type ShutdownMsg struct {
+	T MessageType
+	M string
+}
+
+type AckMsg struct {
+	T MessageType
+	S SeqNumType
+}
+
 type PlayerInitMsg struct {
 	T MessageType
 	Id IdType
@@ -103,6 +128,13 @@ type LevelInitMsg struct {
 	S LevelSeedType
 }
 
+// Spectators send a direction to cycle through players, the server replies with the player to watch
+type SpectateMsg struct {
+	T MessageType
+	Id IdType
+	D int
+}
+
 type KeyMsg struct {
 	T MessageType
 	S SeqNumType
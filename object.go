@@ -28,7 +28,7 @@ type Object interface {
 	AddConnection(parent SpacedId, connection Connection)
 	GetConnections() map[SpacedId]Connection
 
-	SetConstantTTL(duration time.Duration)
+	SetConstantTTL(duration time.Duration, now time.Time)
 	SetVariableTTL(duration time.Duration)
 	RemoveTTL()
 
@@ -24,7 +24,7 @@ func (b *Bomb) Update(grid *Grid, now time.Time) {
 	b.PrepareUpdate(now)
 	b.BaseObject.Update(grid, now)
 
-	if b.Expired() {
+	if b.Expired(now) {
 		pos := b.Pos()
 		dim := b.Dim()
 		dim.Scale(3.6)
@@ -70,7 +70,13 @@ func NewPortal(init Init) *Portal {
 
 func (p *Portal) SetTeam(team uint8) {
 	p.SetByteAttribute(teamByteAttribute, team)
-	p.SetIntAttribute(colorIntAttribute, teamColors[team])
+	p.SetIntAttribute(colorIntAttribute, TeamColor(team))
+}
+
+// Turns the portal into a lobby vote pad for the game mode
+func (p *Portal) SetMode(mode GameModeIdType) {
+	p.SetByteAttribute(modeByteAttribute, uint8(mode))
+	p.SetIntAttribute(colorIntAttribute, gameModes[mode].color)
 }
 
 type Goal struct {
@@ -111,7 +117,7 @@ func (g *Goal) Update(grid *Grid, now time.Time) {
 		collider := PopObject(&colliders)
 		switch object := collider.(type) {
 		case *Player:
-			if object.HasAttribute(vipAttribute) && object.grounded {
+			if (object.HasAttribute(vipAttribute) || object.HasAttribute(carryingAttribute)) && object.grounded {
 				if playerTeam, ok := object.GetByteAttribute(teamByteAttribute); ok && playerTeam == team {
 					hasPlayer = true
 				}
@@ -122,7 +128,7 @@ func (g *Goal) Update(grid *Grid, now time.Time) {
 	if hasPlayer != g.HasAttribute(chargingAttribute) {
 		if hasPlayer {
 			g.AddAttribute(chargingAttribute)
-			g.chargeTimer.Start()
+			g.chargeTimer.Start(now)
 		} else {
 			g.RemoveAttribute(chargingAttribute)
 			g.RemoveAttribute(chargedAttribute)
@@ -130,7 +136,7 @@ func (g *Goal) Update(grid *Grid, now time.Time) {
 		}
 	}
 
-	if g.HasAttribute(chargingAttribute) && g.chargeTimer.Finished() {
+	if g.HasAttribute(chargingAttribute) && g.chargeTimer.Finished(now) {
 		g.AddAttribute(chargedAttribute)
 	}
 
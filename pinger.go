@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	pingInterval time.Duration = 500 * time.Millisecond
+	maxPings int = 4
+)
+
+// Measures a client's round trip time from pings stamped by the server, so a client can't
+// report a high ping to get more lag compensation.
+type Pinger struct {
+	seqNum SeqNumType
+	sent [maxPings]time.Time
+	pings [maxPings]time.Duration
+	numPings int
+}
+
+func NewPinger() Pinger {
+	return Pinger {
+		seqNum: 0,
+		numPings: 0,
+	}
+}
+
+func (p *Pinger) Next(now time.Time) SeqNumType {
+	seqNum := p.seqNum
+	p.sent[int(seqNum) % maxPings] = now
+	p.seqNum += 1
+	return seqNum
+}
+
+// Returns the average of the last few round trips, capped at the most we'll rewind.
+// Echoes for pings we didn't send or already heard back from are ignored.
+func (p *Pinger) Echo(seqNum SeqNumType, now time.Time) (time.Duration, bool) {
+	if seqNum >= p.seqNum || p.seqNum - seqNum > SeqNumType(maxPings) {
+		return 0, false
+	}
+	index := int(seqNum) % maxPings
+	if p.sent[index].IsZero() {
+		return 0, false
+	}
+
+	p.pings[index] = now.Sub(p.sent[index])
+	p.sent[index] = time.Time{}
+	if p.numPings < maxPings {
+		p.numPings += 1
+	}
+
+	var total time.Duration
+	for _, ping := range(p.pings) {
+		total += ping
+	}
+	ping := total / time.Duration(p.numPings)
+	if ping > maxRewindTime {
+		ping = maxRewindTime
+	}
+	return ping, true
+}
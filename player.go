@@ -24,6 +24,8 @@ const (
 	knockbackForceSquared = 50
 
 	jumpVel = 10.0
+	// About one floor up with a double jump
+	jumpPadVel = 24.0
 
 	friction = 0.4
 	knockbackFriction = 1.0
@@ -43,11 +45,17 @@ type Player struct {
 	equip *Equip
 	respawn Vec2
 	grounded bool
+	onJumpPad bool
+	// Velocity of the moving wall we're standing on, or last stood on while in the air
+	platformVel Vec2
 
 	jumpTimer Timer
 	jumpGraceTimer Timer
 	knockbackTimer Timer
 	respawnTimer Timer
+
+	// Round trip time measured by the server, used to rewind targets when shooting
+	ping time.Duration
 }
 
 func NewPlayer(init Init) *Player {
@@ -76,11 +84,15 @@ func NewPlayer(init Init) *Player {
 		weapon: nil,
 		equip: nil,
 		grounded: false,
+		onJumpPad: false,
+		platformVel: NewVec2(0, 0),
 
 		jumpTimer: NewTimer(jumpDuration),
 		jumpGraceTimer: NewTimer(jumpGraceDuration),
 		knockbackTimer: NewTimer(knockbackDuration),
 		respawnTimer: NewTimer(2 * time.Second),
+
+		ping: 0,
 	}
 
 	player.SetByteAttribute(typeByteAttribute, 0)
@@ -91,6 +103,9 @@ func NewPlayer(init Init) *Player {
 func (p Player) GetData() Data {
 	data := p.BaseObject.GetData()
 	data.Set(keysProp, p.GetKeys())
+	if seqNum := p.SeqNum(); seqNum > 0 {
+		data.Set(inputProp, seqNum)
+	}
 	return data
 }
 
@@ -116,7 +131,9 @@ func (p *Player) UpdateScore(g *Grid) {
 		p.SetIntAttribute(deathIntAttribute, 1)
 	}
 
-	sid := p.Health.GetLastDamageId(lastDamageTime)
+	sid := p.Health.GetLastDamageId(lastDamageTime, g.Now())
+	g.GetStats().RecordDeath(g, p, sid, p.Health.GetLastTicks(assistTime, g.Now()))
+
 	object := g.Get(sid)
 	if object != nil {
 		if kills, ok := object.GetIntAttribute(killIntAttribute); ok {
@@ -127,9 +144,17 @@ func (p *Player) UpdateScore(g *Grid) {
 	}
 }
 
+func (p *Player) SetPing(ping time.Duration) {
+	p.ping = ping
+}
+
+func (p Player) GetPing() time.Duration {
+	return p.ping
+}
+
 func (p *Player) SetTeam(team uint8) {
 	p.SetByteAttribute(teamByteAttribute, team)
-	p.SetIntAttribute(colorIntAttribute, teamColors[team])
+	p.SetIntAttribute(colorIntAttribute, TeamColor(team))
 }
 
 func (p *Player) SetSpawn(g *Grid) {
@@ -137,14 +162,18 @@ func (p *Player) SetSpawn(g *Grid) {
 
 	for _, spawn := range(g.GetObjects(spawnSpace)) {
 		if spawnTeam, ok := spawn.GetByteAttribute(teamByteAttribute); ok && team == spawnTeam {
-			pos := spawn.Pos()
-			pos.X += float64(int(p.GetId()) % int(spawn.Dim().X)) - spawn.Dim().X/2
-			p.SetInitPos(pos)
+			p.SetSpawnAt(spawn)
 			break
 		}
 	}
 }
 
+func (p *Player) SetSpawnAt(spawn Object) {
+	pos := spawn.Pos()
+	pos.X += float64(int(p.GetId()) % int(spawn.Dim().X)) - spawn.Dim().X/2
+	p.SetInitPos(pos)
+}
+
 func (p *Player) Respawn() {
 	p.Health.Respawn()
 	p.SetHealth(100)
@@ -153,6 +182,7 @@ func (p *Player) Respawn() {
 
 	p.SetPos(p.InitPos())
 	p.Stop()
+	p.platformVel = NewVec2(0, 0)
 	p.AddAttribute(canDoubleJumpAttribute)
 }
 
@@ -160,7 +190,7 @@ func (p *Player) Update(grid *Grid, now time.Time) {
 	ts := p.PrepareUpdate(now)
 	p.BaseObject.Update(grid, now)
 
-	if p.Expired() {
+	if p.Expired(now) {
 		grid.Delete(p.GetSpacedId())
 		return
 	}
@@ -176,10 +206,10 @@ func (p *Player) Update(grid *Grid, now time.Time) {
 			p.AddAttribute(deadAttribute)
 			p.Keys.SetEnabled(false)
 			p.UpdateScore(grid)
-			p.respawnTimer.Start()
+			p.respawnTimer.Start(now)
 		}
 
-		if p.HasAttribute(autoRespawnAttribute) && !p.respawnTimer.On() {
+		if p.HasAttribute(autoRespawnAttribute) && !p.respawnTimer.On(now) {
 			p.RemoveAttribute(deadAttribute)
 			p.Keys.SetEnabled(true)
 			p.Respawn()
@@ -191,17 +221,17 @@ func (p *Player) Update(grid *Grid, now time.Time) {
 	pos := p.Pos()
 
 	if p.grounded {
-		p.jumpGraceTimer.Start()
+		p.jumpGraceTimer.Start(now)
 		p.AddAttribute(canJumpAttribute)
 		p.AddAttribute(canDoubleJumpAttribute)
-	} else if !p.jumpGraceTimer.On() {
+	} else if !p.jumpGraceTimer.On(now) {
 		p.RemoveAttribute(canJumpAttribute)
 	}
 
 	// Gravity & air resistance
 	acc.Y = gravityAcc
 	if !p.grounded {
-		if !p.jumpTimer.On() || vel.Y <= 0 {
+		if !p.jumpTimer.On(now) || vel.Y <= 0 {
 			acc.Y += downAcc
 		}
 	}
@@ -226,22 +256,27 @@ func (p *Player) Update(grid *Grid, now time.Time) {
 
 	// Jump & double jump
 	if p.KeyDown(jumpKey) {
-		if p.jumpGraceTimer.On() {
+		if p.jumpGraceTimer.On(now) {
 			p.jumpGraceTimer.Stop()
-			vel.Y = jumpVel
-			p.jumpTimer.Start()
+			vel.Y = jumpVel + Max(0, p.platformVel.Y)
+			p.jumpTimer.Start(now)
 		} else if p.KeyPressed(jumpKey) && p.HasAttribute(canDoubleJumpAttribute) {
 			vel.Y = jumpVel
 			p.RemoveAttribute(canDoubleJumpAttribute)
-			p.jumpTimer.Start()
+			p.jumpTimer.Start(now)
 		}
 	}
+	if p.onJumpPad {
+		p.jumpGraceTimer.Stop()
+		vel.Y = jumpPadVel
+		p.jumpTimer.Start(now)
+	}
 
 	// Friction
 	if p.grounded {
 		if Sign(acc.X) != Sign(vel.X) {
-			if p.knockbackTimer.On() {
-				vel.X *= p.knockbackTimer.Lerp(knockbackFriction, friction)
+			if p.knockbackTimer.On(now) {
+				vel.X *= p.knockbackTimer.Lerp(now, knockbackFriction, friction)
 			} else {
 				vel.X *= friction
 			}
@@ -268,7 +303,7 @@ func (p *Player) Update(grid *Grid, now time.Time) {
 	}
 	p.SetVel(vel)
 	if force := p.ApplyForces(); force.LenSquared() > knockbackForceSquared {
-		p.knockbackTimer.Start()
+		p.knockbackTimer.Start(now)
 	}
 
 	// Move
@@ -299,6 +334,39 @@ func (p *Player) checkCollisions(grid *Grid) {
 	snapResults := p.Snap(colliders)
 	p.grounded = snapResults.posAdjustment.Y > 0
 
+	// Launched on the next update if we landed on a jump pad
+	p.onJumpPad = false
+	// Carried along by the wall we're standing on
+	if p.grounded {
+		p.platformVel = NewVec2(0, 0)
+	}
+	for sid, result := range(snapResults.collideResults) {
+		if !p.grounded || !result.GetHit() || result.GetPosAdjustment().Y <= 0 {
+			continue
+		}
+		ground := grid.Get(sid)
+		if subtype, ok := ground.GetByteAttribute(subtypeByteAttribute); ok && WallSubtype(subtype) == jumpPadWallSubtype {
+			p.onJumpPad = true
+		}
+		if vel := ground.Vel(); !vel.IsZero() {
+			p.platformVel = vel
+		}
+	}
+
+	// Snapping already carries us while grounded. In the air, drift with the platform for a bit
+	// so a jump doesn't slide off the back, slowing down like any other horizontal velocity.
+	if !p.grounded {
+		if snapResults.snap {
+			p.platformVel = NewVec2(0, 0)
+		} else if p.platformVel.X != 0 {
+			p.SetExtVel(NewVec2(p.platformVel.X, 0))
+			p.platformVel.X *= airResistance
+			if Abs(p.platformVel.X) < zeroVelEpsilon {
+				p.platformVel.X = 0
+			}
+		}
+	}
+
 	colliders = grid.GetColliders(p)
 	for len(colliders) > 0 {
 		collider := PopObject(&colliders)
@@ -330,6 +398,9 @@ func (p *Player) checkCollisions(grid *Grid) {
 				if team, ok := object.GetByteAttribute(teamByteAttribute); ok {
 					p.SetTeam(team)
 				}
+				if mode, ok := object.GetByteAttribute(modeByteAttribute); ok {
+					p.SetByteAttribute(voteByteAttribute, mode)
+				}
 			}
 		}
 	}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func newMovingWall(grid *Grid, id IdType, pos Vec2, dim Vec2, speed float64, end Vec2) *Wall {
+	wall := NewWall(NewInit(Id(wallSpace, id), pos, dim))
+	wall.SetByteAttribute(typeByteAttribute, uint8(platformWall))
+	wall.SetSpeed(speed)
+	wall.AddWaypoint(pos)
+	wall.AddWaypoint(end)
+	grid.Upsert(wall)
+	return wall
+}
+
+// Stands a player on top of the wall, a little above so the first update lands them.
+func newPlayerOnWall(grid *Grid, wall *Wall) *Player {
+	pos := wall.Pos()
+	pos.Y += wall.Dim().Y / 2 + 0.72 + 0.01
+	player := NewPlayer(NewInit(Id(playerSpace, 1), pos, NewVec2(0.8, 1.44)))
+	grid.Upsert(player)
+	return player
+}
+
+func updateGrid(grid *Grid, now time.Time, frames int) time.Time {
+	for i := 0; i < frames; i += 1 {
+		now = now.Add(frameTime)
+		grid.Update(now)
+	}
+	return now
+}
+
+// Checks the player stays grounded and keeps the same offset from the wall while it moves.
+func checkRiding(t *testing.T, grid *Grid, player *Player, wall *Wall, frames int) {
+	now := updateGrid(grid, time.Now(), 5)
+	start := wall.Pos()
+	offset := player.Pos()
+	offset.Sub(wall.Pos(), 1.0)
+
+	for i := 0; i < frames; i += 1 {
+		now = updateGrid(grid, now, 1)
+		if !player.grounded {
+			t.Fatalf("player fell off the platform after %d frames", i + 1)
+		}
+
+		current := player.Pos()
+		current.Sub(wall.Pos(), 1.0)
+		if math.Abs(current.X - offset.X) > 0.1 || math.Abs(current.Y - offset.Y) > 0.1 {
+			t.Fatalf("player is at %v from the platform after %d frames, expected %v", current, i + 1, offset)
+		}
+	}
+
+	if wall.Pos().DistanceSquared(start) < 1 {
+		t.Fatalf("platform only moved from %v to %v", start, wall.Pos())
+	}
+}
+
+func TestPlayerRidesSlidingPlatform(t *testing.T) {
+	grid := NewGrid(4, 4)
+	wall := newMovingWall(grid, 1, NewVec2(0, 0), NewVec2(slidingPlatformWidth, 0.5), slidingPlatformSpeed, NewVec2(10, 0))
+	checkRiding(t, grid, newPlayerOnWall(grid, wall), wall, 60)
+}
+
+func TestPlayerRidesElevator(t *testing.T) {
+	grid := NewGrid(4, 4)
+	wall := newMovingWall(grid, 1, NewVec2(0, 0), NewVec2(3, 0.5), elevatorSpeed, NewVec2(0, 6))
+	checkRiding(t, grid, newPlayerOnWall(grid, wall), wall, 60)
+}
@@ -4,6 +4,13 @@ import (
 	"time"
 )
 
+// How long after being fired a projectile is checked against players rewound by the shooter's ping.
+// Only fast projectiles need this, slow ones can be dodged so they use current positions.
+var lagCompensationTimes = map[SpaceType]time.Duration {
+	boltSpace: 100 * time.Millisecond,
+	pelletSpace: 100 * time.Millisecond,
+}
+
 type ExplosionOptions struct {
 	explode bool
 	size Vec2
@@ -18,6 +25,7 @@ type Projectile struct {
 	sticky bool
 	collider Object
 	target SpacedId
+	firedAt time.Time
 
 	explosionOptions ExplosionOptions
 }
@@ -31,6 +39,7 @@ func NewProjectile(object BaseObject) Projectile {
 		sticky: false,
 		collider: nil,
 		target: InvalidId(),
+		firedAt: time.Time{},
 
 		explosionOptions: ExplosionOptions{
 			explode: false,
@@ -69,6 +78,9 @@ func (p *Projectile) Charge() {}
 func (p *Projectile) Update(grid *Grid, now time.Time) {
 	ts := p.PrepareUpdate(now)
 	p.BaseObject.Update(grid, now)
+	if p.firedAt.IsZero() {
+		p.firedAt = now
+	}
 
 	acc := p.Acc()
 	acc.Add(p.Jerk(), ts)
@@ -94,30 +106,115 @@ func (p *Projectile) Update(grid *Grid, now time.Time) {
 		return
 	}
 
-	if p.Expired() || (p.collider != nil && !p.sticky) {
+	if p.Expired(now) || (p.collider != nil && !p.sticky) {
 		p.SelfDestruct(grid)
 		return
 	}
 
+	// Already stuck, the attachment keeps us on whatever we hit even if it moves
+	if p.collider != nil {
+		grid.Upsert(p)
+		return
+	}
 
 	var colliders ObjectHeap
+	var line *Line
 	movement := p.Pos()
 	movement.Sub(lastPos, 1.0)
 	if movement.LenSquared() > 0 {
-		line := NewLine(lastPos, movement)
-		colliders = grid.GetCollidersCheckLine(p, line)
+		l := NewLine(lastPos, movement)
+		line = &l
+		colliders = grid.GetCollidersCheckLine(p, l)
 	} else {
 		colliders = grid.GetColliders(p)
 	}
+
+	rewound := make(map[SpacedId]Profile)
+	if rewind := p.getRewind(grid, now); rewind > 0 {
+		colliders, rewound = p.rewindColliders(grid, colliders, line, now.Add(-rewind))
+	}
+
 	if len(colliders) > 0 {
 		object := PopObject(&colliders)
-		result := p.OverlapProfile(object.GetProfile())
+		profile := object.GetProfile()
+		if past, ok := rewound[object.GetSpacedId()]; ok {
+			profile = past
+		}
+		result := p.OverlapProfile(profile)
 		p.Stick(result)
 		p.Collide(object, grid)
 	}
 	grid.Upsert(p)
 }
 
+// Returns how far back to check players, or 0 if this projectile shouldn't be lag compensated.
+func (p *Projectile) getRewind(grid *Grid, now time.Time) time.Duration {
+	window, ok := lagCompensationTimes[p.GetSpace()]
+	if !ok || now.Sub(p.firedAt) > window {
+		return 0
+	}
+
+	owner := grid.Get(p.GetOwner())
+	if owner == nil {
+		return 0
+	}
+	player, ok := owner.(*Player)
+	if !ok {
+		return 0
+	}
+
+	// The shooter sees other players as they were one way trip ago
+	rewind := player.GetPing() / 2
+	if rewind > maxRewindTime {
+		rewind = maxRewindTime
+	}
+	return rewind
+}
+
+// Replaces players in the colliders with where they were at the given time.
+func (p *Projectile) rewindColliders(grid *Grid, colliders ObjectHeap, line *Line, t time.Time) (ObjectHeap, map[SpacedId]Profile) {
+	heap := make(ObjectHeap, 0)
+	rewound := make(map[SpacedId]Profile)
+
+	for _, item := range(colliders) {
+		if item.object.GetSpace() == playerSpace {
+			continue
+		}
+		other := &ObjectItem {
+			object: item.object,
+		}
+		heap.Push(other)
+		heap.Priority(other, item.priority)
+	}
+
+	for _, player := range(grid.GetObjects(playerSpace)) {
+		if !p.GetOverlapOptions().Evaluate(player) {
+			continue
+		}
+		profile, ok := grid.GetPastProfile(player.GetSpacedId(), t)
+		if !ok {
+			continue
+		}
+
+		priority := 0.0
+		if results := p.OverlapProfile(profile); results.hit {
+			priority = results.GetPosAdjustment().Area()
+		} else if line != nil && profile.Intersects(*line).hit {
+			priority = p.Dim().Area() + 1
+		} else {
+			continue
+		}
+
+		item := &ObjectItem {
+			object: player,
+		}
+		heap.Push(item)
+		heap.Priority(item, priority)
+		rewound[player.GetSpacedId()] = profile
+	}
+	return heap, rewound
+}
+
 func (p *Projectile) Collide(collider Object, grid *Grid) {
 	if p.collider != nil {
 		return
@@ -150,7 +247,11 @@ func (p *Projectile) Hit(grid *Grid, collider Object) {
 
 	switch object := collider.(type) {
 	case *Player:
-		object.TakeDamage(p.GetOwner(), p.GetDamage())
+		health := object.GetHealth()
+		object.TakeDamage(p.GetOwner(), p.GetDamage(), grid.Now())
+		if !isWasm {
+			grid.GetStats().RecordDamage(grid.Get(p.GetOwner()), object, projectileWeapons[p.GetSpace()], health - object.GetHealth())
+		}
 	}
 }
 
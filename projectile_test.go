@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// The wall turns around while the star is stuck to it, so it moves back into the star.
+func TestStickyProjectileFollowsMovingWall(t *testing.T) {
+	grid := NewGrid(4, 4)
+	end := NewVec2(0.5, 0)
+	wall := newMovingWall(grid, 1, NewVec2(0, 0), NewVec2(1, 4), slidingPlatformSpeed, end)
+
+	star := NewStar(NewInit(Id(starSpace, 1), NewVec2(-2, 1), NewVec2(0.3, 0.3)))
+	star.SetVel(NewVec2(20, -5))
+	grid.Upsert(star)
+
+	now := time.Now()
+	for i := 0; i < 30 && !star.HasAttribute(attachedAttribute); i += 1 {
+		now = updateGrid(grid, now, 1)
+	}
+	if !star.HasAttribute(attachedAttribute) {
+		t.Fatalf("star never stuck to the wall, ended up at %v", star.Pos())
+	}
+
+	offset := star.Pos()
+	offset.Sub(wall.Pos(), 1.0)
+
+	turned := false
+	for i := 0; i < 30; i += 1 {
+		now = updateGrid(grid, now, 1)
+		turned = turned || wall.Pos().DistanceSquared(end) <= 1e-4
+		if !grid.Has(star.GetSpacedId()) {
+			t.Fatalf("star was deleted after %d frames", i + 1)
+		}
+
+		current := star.Pos()
+		current.Sub(wall.Pos(), 1.0)
+		if math.Abs(current.X - offset.X) > 1e-6 || math.Abs(current.Y - offset.Y) > 1e-6 {
+			t.Fatalf("star is at %v from the wall after %d frames, expected %v", current, i + 1, offset)
+		}
+	}
+
+	if !turned {
+		t.Fatalf("wall never reached %v, ended up at %v", end, wall.Pos())
+	}
+}
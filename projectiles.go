@@ -1,7 +1,6 @@
 package main
 
 import (
-	"math/rand"
 	"time"
 )
 
@@ -92,8 +91,8 @@ func NewStar(init Init) *Star {
 		Projectile: NewProjectile(NewCircleObject(init)),
 	}
 
-	r := rand.New(rand.NewSource(UnixMilli()))
-	color := starColors[r.Intn(len(starColors))]
+	// Pick by id so the color doesn't depend on when the star was thrown
+	color := starColors[int(init.GetId()) % len(starColors)]
 
 	star.SetVariableTTL(shortRange)
 	star.SetExplosionOptions(ExplosionOptions {
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// Inputs past this are dropped so a long match can't grow the replay forever
+	maxReplayEvents int = 200000
+)
+
+type ReplayEventType uint8
+const (
+	unknownReplayEvent ReplayEventType = iota
+	levelReplayEvent
+	joinReplayEvent
+	leaveReplayEvent
+	keyReplayEvent
+	pingReplayEvent
+	teamReplayEvent
+	modeReplayEvent
+	restartReplayEvent
+)
+
+// Only the fields relevant to the event type are set
+type ReplayEvent struct {
+	T ReplayEventType
+	S SeqNumType
+
+	Id IdType `msgpack:",omitempty"`
+	Name string `msgpack:",omitempty"`
+	Bot bool `msgpack:",omitempty"`
+	Key *KeyMsg `msgpack:",omitempty"`
+	Ping int `msgpack:",omitempty"`
+	Team uint8 `msgpack:",omitempty"`
+
+	Mode GameModeIdType `msgpack:",omitempty"`
+	Options map[string]int `msgpack:",omitempty"`
+
+	L LevelIdType `msgpack:",omitempty"`
+	Seed LevelSeedType `msgpack:",omitempty"`
+}
+
+// Each replay covers one match, starting from the lobby before it.
+type Replay struct {
+	V string
+	Room string
+	Start int64
+
+	// Game state the replay starts from
+	Seq SeqNumType
+	Seed int64
+	Mode GameModeIdType
+	ModeOptions map[GameModeIdType]map[string]int `msgpack:",omitempty"`
+	MatchLevel LevelIdType
+
+	Events []ReplayEvent
+	Truncated bool
+}
+
+func NewReplay(room string, seqNum SeqNumType) *Replay {
+	return &Replay {
+		V: gameVersion,
+		Room: room,
+		Start: UnixMilli(),
+		Seq: seqNum,
+		Events: make([]ReplayEvent, 0),
+		Truncated: false,
+	}
+}
+
+func (r *Replay) RecordLevel(seqNum SeqNumType, id LevelIdType, seed LevelSeedType) {
+	r.Events = append(r.Events, ReplayEvent {
+		T: levelReplayEvent,
+		S: seqNum,
+		L: id,
+		Seed: seed,
+	})
+}
+
+func (r *Replay) RecordJoin(seqNum SeqNumType, id IdType, name string, bot bool) {
+	r.Events = append(r.Events, ReplayEvent {
+		T: joinReplayEvent,
+		S: seqNum,
+		Id: id,
+		Name: name,
+		Bot: bot,
+	})
+}
+
+func (r *Replay) RecordLeave(seqNum SeqNumType, id IdType) {
+	r.Events = append(r.Events, ReplayEvent {
+		T: leaveReplayEvent,
+		S: seqNum,
+		Id: id,
+	})
+}
+
+func (r *Replay) RecordKey(seqNum SeqNumType, id IdType, keyMsg KeyMsg) {
+	if len(r.Events) >= maxReplayEvents {
+		r.Truncated = true
+		return
+	}
+	r.Events = append(r.Events, ReplayEvent {
+		T: keyReplayEvent,
+		S: seqNum,
+		Id: id,
+		Key: &keyMsg,
+	})
+}
+
+func (r *Replay) RecordPing(seqNum SeqNumType, id IdType, ping time.Duration) {
+	if len(r.Events) >= maxReplayEvents {
+		r.Truncated = true
+		return
+	}
+	r.Events = append(r.Events, ReplayEvent {
+		T: pingReplayEvent,
+		S: seqNum,
+		Id: id,
+		Ping: int(ping / time.Millisecond),
+	})
+}
+
+func (r *Replay) RecordTeam(seqNum SeqNumType, id IdType, team uint8) {
+	r.Events = append(r.Events, ReplayEvent {
+		T: teamReplayEvent,
+		S: seqNum,
+		Id: id,
+		Team: team,
+	})
+}
+
+func (r *Replay) RecordMode(seqNum SeqNumType, mode GameModeIdType, options map[string]int) {
+	r.Events = append(r.Events, ReplayEvent {
+		T: modeReplayEvent,
+		S: seqNum,
+		Mode: mode,
+		Options: options,
+	})
+}
+
+func (r *Replay) RecordRestart(seqNum SeqNumType) {
+	r.Events = append(r.Events, ReplayEvent {
+		T: restartReplayEvent,
+		S: seqNum,
+	})
+}
+
+// Feeds recorded events back into a fresh game, one frame at a time.
+type ReplayPlayer struct {
+	replay *Replay
+	index int
+}
+
+func NewReplayPlayer(replay *Replay, game *Game) *ReplayPlayer {
+	rp := &ReplayPlayer {
+		replay: replay,
+		index: 0,
+	}
+
+	game.SetSeed(replay.Seed)
+	for mode, options := range(replay.ModeOptions) {
+		game.GetGrid().SetModeOptions(mode, options)
+	}
+	if replay.Mode != unknownGameMode {
+		game.GetGrid().SetGameMode(replay.Mode)
+	}
+	if replay.MatchLevel != unknownLevel {
+		game.SetMatchLevel(replay.MatchLevel)
+	}
+
+	// Levels after the first are loaded by the game mode, so only the seeds are needed.
+	first := true
+	for _, event := range(replay.Events) {
+		if event.T != levelReplayEvent {
+			continue
+		}
+		if first {
+			game.LoadLevel(event.L, event.Seed)
+			first = false
+		} else {
+			game.QueueLevelSeed(event.Seed)
+		}
+	}
+	return rp
+}
+
+func (rp ReplayPlayer) Done() bool {
+	return rp.index >= len(rp.replay.Events)
+}
+
+// Returns the largest player id so spectators don't collide with recorded players.
+func (rp ReplayPlayer) MaxId() IdType {
+	max := IdType(0)
+	for _, event := range(rp.replay.Events) {
+		if event.T == joinReplayEvent && event.Id > max {
+			max = event.Id
+		}
+	}
+	return max
+}
+
+// Apply everything recorded before the game's current frame.
+func (rp *ReplayPlayer) Apply(game *Game) {
+	for ; rp.index < len(rp.replay.Events); rp.index += 1 {
+		event := rp.replay.Events[rp.index]
+		if event.S - rp.replay.Seq > game.seqNum {
+			return
+		}
+
+		switch event.T {
+		case joinReplayEvent:
+			if event.Bot {
+				game.AddBot(event.Id, event.Name)
+			} else {
+				game.JoinPlayer(event.Id, event.Name)
+			}
+		case leaveReplayEvent:
+			if game.HasBot(event.Id) {
+				game.RemoveBot(event.Id)
+			} else {
+				game.LeavePlayer(event.Id)
+			}
+		case keyReplayEvent:
+			if event.Key != nil {
+				game.ApplyKeyMsg(event.Id, *event.Key)
+			}
+		case pingReplayEvent:
+			game.SetPing(event.Id, time.Duration(event.Ping) * time.Millisecond)
+		case teamReplayEvent:
+			game.SetTeam(event.Id, event.Team)
+		case modeReplayEvent:
+			game.SetGameMode(event.Mode, event.Options)
+		case restartReplayEvent:
+			game.RestartGameMode()
+		}
+	}
+}
@@ -31,6 +31,7 @@ func (rb *RoofBlock) AddHut() {
 	}
 
 	rb.Append(hut)
+	rb.occupied.Add(bottomCardinal)
 }
 
 func (rb *RoofBlock) LoadTemplate(template BlockTemplate) {
@@ -63,6 +64,19 @@ func (rb *RoofBlock) LoadTemplate(template BlockTemplate) {
 		rb.objects = append(rb.objects, sniper)
 
 		rb.occupied.AddAll(bottomLeftCardinal, bottomCardinal, bottomRightCardinal) 
+
+	case jumpPadBlockTemplate:
+		if rb.occupied.Get(bottomCardinal) || rb.openings.AnyBottom() {
+			break
+		}
+
+		pad := NewWall(NewInitC(Id(wallSpace, 0), NewVec2(x, y + rb.thick), NewVec2(2, 0.25), bottomCardinal))
+		pad.SetByteAttribute(subtypeByteAttribute, uint8(jumpPadWallSubtype))
+		pad.AddAttribute(visibleAttribute)
+		pad.SetIntAttribute(colorIntAttribute, jumpPadColor)
+		rb.objects = append(rb.objects, pad)
+
+		rb.occupied.Add(bottomCardinal)
 	}
 }
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"log"
@@ -11,106 +12,292 @@ import (
 
 const (
 	isWasm bool = false
+
+	// Bots fill the room up to this many players
+	minRoomPlayers int = 4
+	defaultMaxRoomPlayers int = 12
+	maxRoomPlayers int = 24
+	maxPasswordLength int = 32
+
+	// Websocket close codes for joins that are turned away
+	wrongPasswordCloseCode int = 4001
+	roomFullCloseCode int = 4002
+	kickedCloseCode int = 4003
+
+	// Stat ticks between logging room load and codec sizes
+	statLogTicks int = 60
 )
 
+// Chosen by whoever creates the room
+type RoomSettings struct {
+	maxPlayers int
+	password string
+	private bool
+}
+
+func NewRoomSettings(vars map[string]string) RoomSettings {
+	settings := RoomSettings {
+		maxPlayers: defaultMaxRoomPlayers,
+		password: vars["password"],
+		private: vars["private"] == "1",
+	}
+	if max, err := strconv.Atoi(vars["max"]); err == nil {
+		settings.maxPlayers = max
+	}
+	return settings
+}
+
+func (s RoomSettings) CheckPassword(password string) bool {
+	if s.password == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+}
+
 type Room struct {
 	name string
+	settings RoomSettings
+	created time.Time
 
 	nextClientId IdType
 	clients map[IdType]*Client
+	owner *Client
 	register chan *Client
-	registerQueue []*Client
 	init chan *Client
-	initQueue []*Client
 	unregister chan *Client
-	unregisterQueue []*Client
+
+	// Registered clients that are still setting up WebRTC
+	pending map[*Client]bool
 
 	deleteTimer Timer
+	quit chan bool
+	done chan bool
 
 	game *Game
+	playback *ReplayPlayer
 	ticker *time.Ticker
 	gameTicks int
 	statTicker *time.Ticker
+	pingTicker *time.Ticker
 
 	chat *Chat
+	extraBots int
+	identities *IdentityStore
+
+	// Object data from one compact client is sampled every stat tick to compare against Pack
+	sampleCodec bool
+	codecStats CodecStats
+	statTicks int
 
 	incoming chan IncomingMsg
-	incomingQueue []IncomingMsg
+	loopStats LoopStats
+}
+
+func NewRoom(name string, vars map[string]string) *Room {
+	return &Room {
+		name: name,
+		settings: NewRoomSettings(vars),
+		created: time.Now(),
+
+		nextClientId: 0,
+		clients: make(map[IdType]*Client),
+		owner: nil,
+		register: make(chan *Client),
+		init: make(chan *Client),
+		unregister: make(chan *Client),
+		pending: make(map[*Client]bool),
+		deleteTimer: NewTimer(30 * time.Second),
+		quit: make(chan bool),
+		done: make(chan bool),
+
+		game: NewGame(),
+		playback: nil,
+		ticker: time.NewTicker(frameTime),
+		gameTicks: 0,
+		statTicker: time.NewTicker(1 * time.Second),
+		pingTicker: time.NewTicker(pingInterval),
+
+		chat: NewChat(),
+		extraBots: 0,
+		identities: NewIdentityStore(),
+
+		sampleCodec: false,
+		codecStats: NewCodecStats(),
+		statTicks: 0,
+
+		incoming: make(chan IncomingMsg),
+		loopStats: NewLoopStats(),
+	}
 }
 
-var rooms = make(map[string]*Room)
-func CreateOrJoinRoom(vars map[string]string, ws *websocket.Conn) {
-	roomName := vars["room"]
-	_, roomExists := rooms[roomName]
+// Pick the client's ID, reusing the requested one if the client proves it had it before.
+func (r *Room) assignClientId(client *Client) {
+	client.id = r.nextClientId
+	if client.requestedId >= 0 {
+		id := IdType(client.requestedId)
+		if _, ok := r.clients[id]; !ok && id < r.nextClientId && !r.game.HasBot(id) && r.verifyReconnectToken(id, client.reconnectToken) {
+			client.id = id
+		} else {
+			r.print(fmt.Sprintf("%s can't reclaim ID %d", client.GetDisplayName(), id))
+		}
+	}
+
+	if client.id >= r.nextClientId {
+		r.nextClientId = client.id + 1
+	}
+	client.target = client.id
+}
 
-	if !roomExists {
-		rooms[roomName] = &Room {
-			name: roomName,
+// Players reconnecting to their old ID are let back in even if the room is full. Clients that
+// are still connecting count too, so joins during the WebRTC handshake can't overfill the room.
+func (r *Room) admitClient(client *Client) bool {
+	if client.IsSpectator() || r.NumPlayers() + r.numPendingPlayers() < r.settings.maxPlayers {
+		return true
+	}
+	return r.game.GetGrid().Has(Id(playerSpace, client.id)) && !r.game.HasBot(client.id)
+}
 
-			nextClientId: 0,
-			clients: make(map[IdType]*Client),
-			register: make(chan *Client),
-			registerQueue: make([]*Client, 0),
-			init: make(chan *Client),
-			initQueue: make([]*Client, 0),
-			unregister: make(chan *Client),
-			unregisterQueue: make([]*Client, 0),
-			deleteTimer: NewTimer(30 * time.Second),
+func (r Room) reconnectToken(id IdType) string {
+	return signReconnectToken(r.name, r.created, id)
+}
 
-			game: NewGame(),
-			ticker: time.NewTicker(frameTime),
-			gameTicks: 0,
-			statTicker: time.NewTicker(1 * time.Second),
+func (r Room) verifyReconnectToken(id IdType, token string) bool {
+	return !r.identities.IsRevoked(id) && verifyReconnectToken(r.name, r.created, id, token)
+}
 
-			chat: NewChat(),
+func (r Room) numPendingPlayers() int {
+	players := 0
+	for client := range(r.pending) {
+		if !client.IsSpectator() {
+			players += 1
+		}
+	}
+	return players
+}
 
-			incoming: make(chan IncomingMsg),
-			incomingQueue: make([]IncomingMsg, 0),
+func (r Room) NumPlayers() int {
+	players := 0
+	for _, client := range(r.clients) {
+		if !client.IsSpectator() {
+			players += 1
 		}
-		rooms[roomName].game.LoadLevel(lobbyLevel, 0)
-		go rooms[roomName].run()
-	}
-
-	r := rooms[roomName]
-	clientId := r.nextClientId
-	if stringId, idOk := vars["id"]; idOk {
-		intId, err := strconv.Atoi(stringId)
-		if err == nil {
-			id := IdType(intId)
-			if _, ok := r.clients[id]; !ok && id < r.nextClientId {
-				clientId = id
-			}
+	}
+	return players
+}
+
+// Snapshot what the room browser needs, since HTTP handlers run on other goroutines.
+func (r *Room) publishStatus() {
+	grid := r.game.GetGrid()
+	state, _ := grid.GetGameState()
+	level := r.game.level.GetId()
+
+	status := RoomStatus {
+		Name: r.name,
+		Players: r.NumPlayers(),
+		Spectators: len(r.clients) - r.NumPlayers(),
+		Bots: len(r.game.GetBotIds()),
+		MaxPlayers: r.settings.maxPlayers,
+		Password: r.settings.password != "",
+		State: gameStateNames[state],
+		StateId: state,
+		Mode: GameModeName(grid.GetGameModeId()),
+		Level: LevelName(level),
+		LevelId: level,
+		Scores: make(map[uint8]int),
+		Uptime: int64(time.Since(r.created) / time.Second),
+		Replay: r.playback != nil,
+		Load: r.loopStats.Load(),
+		TickJitterMs: float64(r.loopStats.AvgJitter()) / float64(time.Millisecond),
+
+		private: r.settings.private,
+	}
+	if scores, ok := grid.GetGameStateProps()[scoreProp].(map[uint8]int); ok {
+		for team, score := range(scores) {
+			status.Scores[team] = score
 		}
 	}
 
-	client := NewClient(r, ws, vars["name"], clientId)
-	if clientId >= r.nextClientId {
-		r.nextClientId = clientId + 1
+	players := make([]PlayerStatus, 0)
+	for _, player := range(sortById(grid.GetObjects(playerSpace))) {
+		playerStatus := PlayerStatus {
+			Id: player.GetId(),
+			Bot: r.game.HasBot(player.GetId()),
+		}
+		if name, ok := player.GetInitData().Get(nameProp).(string); ok {
+			playerStatus.Name = name
+		}
+		playerStatus.Team, _ = player.GetByteAttribute(teamByteAttribute)
+		playerStatus.Kills, _ = player.GetIntAttribute(killIntAttribute)
+		playerStatus.Deaths, _ = player.GetIntAttribute(deathIntAttribute)
+		players = append(players, playerStatus)
 	}
-	r.register <- client
+
+	roomStatuses.Set(RoomDetail {
+		RoomStatus: status,
+		PlayerList: players,
+	})
 }
 
 func (r *Room) run() {
 	defer func() {
+		r.ticker.Stop()
+		r.statTicker.Stop()
+		r.pingTicker.Stop()
+
+		if replay := r.game.GetReplay(); replay != nil {
+			r.saveReplay(replay)
+		}
+
 		r.print("deleted room")
-		delete(rooms, r.name)
+		roomStatuses.Delete(r.name)
+		close(r.done)
 	}()
 
 	for {
 		select {
+		case <-r.quit:
+			r.shutdown()
+			return
 		case client := <-r.register:
-			r.registerQueue = append(r.registerQueue, client)
+			start := time.Now()
+			r.handleRegister(client)
+			r.loopStats.AddBusy(time.Since(start))
 		case client := <-r.init:
-			r.initQueue = append(r.initQueue, client)
+			start := time.Now()
+			r.handleInit(client)
+			r.loopStats.AddBusy(time.Since(start))
 		case client := <-r.unregister:
-			r.unregisterQueue = append(r.unregisterQueue, client)
+			start := time.Now()
+			r.handleUnregister(client)
+			r.loopStats.AddBusy(time.Since(start))
 		case imsg := <-r.incoming:
-			r.incomingQueue = append(r.incomingQueue, imsg)
-		case _ = <-r.ticker.C:
+			start := time.Now()
+			r.handleIncoming(imsg)
+			r.loopStats.AddBusy(time.Since(start))
+		case <-r.ticker.C:
+			start := time.Now()
+			r.loopStats.Tick(start)
+			if r.playback != nil {
+				r.playback.Apply(r.game)
+			}
 			updates := r.game.Update()
 			r.sendGameState(updates)
+			for _, replay := range(r.game.TakeFinishedReplays()) {
+				r.saveReplay(replay)
+			}
 			r.gameTicks += 1
+			r.loopStats.AddBusy(time.Since(start))
+		case <-r.pingTicker.C:
+			start := time.Now()
+			r.sendPings(start)
+			r.loopStats.AddBusy(time.Since(start))
 		case _ = <-r.statTicker.C:
+			// Rooms are only deleted here, so no one can join while this one shuts down
+			if r.deleteTimer.Finished(time.Now()) && roomManager.deleteIfEmpty(r) {
+				return
+			}
+
+			r.publishStatus()
 			if len(r.clients) == 0 {
 				continue
 			}
@@ -118,67 +305,112 @@ func (r *Room) run() {
 				r.print(fmt.Sprintf("slow FPS: %d", r.gameTicks))
 			}
 			r.gameTicks = 0
-		default:
-			if len(r.registerQueue) > 0 {
-				for _, client := range(r.registerQueue) {
-					err := r.registerClient(client)
-					if err != nil {
-						r.unregister <- client
-					}
-				}
-				r.registerQueue = r.registerQueue[:0]
-			}
 
-			if len(r.initQueue) > 0 {
-				for _, client := range(r.initQueue) {
-					err := r.initClient(client)
-					if err != nil {
-						r.unregister <- client
-					}
-				}
-				r.initQueue = r.initQueue[:0]
+			if skipped := r.game.FlushSkippedInputs(); skipped > 0 {
+				r.print(fmt.Sprintf("skipped %d inputs", skipped))
 			}
 
-			if len(r.unregisterQueue) > 0 {
-				for _, client := range(r.unregisterQueue) {
-					r.unregisterClient(client)
+			r.statTicks += 1
+			if r.statTicks % statLogTicks == 0 {
+				r.identities.Prune(time.Now())
+				r.print(r.loopStats.String())
+				r.loopStats.Reset()
+				if !r.codecStats.Empty() {
+					r.print(r.codecStats.String())
+					r.codecStats.Reset()
 				}
-				r.unregisterQueue = r.unregisterQueue[:0]
 			}
+			r.sampleCodec = true
+		}
+	}
+}
 
-			if len(r.clients) == 0 {
-				if !r.deleteTimer.Started() {
-					r.print("started countdown to delete room")
-					r.deleteTimer.Start()
-				}
+func (r *Room) handleRegister(client *Client) {
+	err := r.registerClient(client)
+	if err != nil {
+		r.print(fmt.Sprintf("failed to register %s: %v", client.GetDisplayName(), err))
+		r.handleUnregister(client)
+		return
+	}
+	r.updateDeleteTimer()
+}
 
-				if r.deleteTimer.Finished() {
-					return
-				}
-			} else if r.deleteTimer.Started() {
-				r.print("stopping deletion due to reconnect")
-				r.deleteTimer.Stop()
-			}
+func (r *Room) handleInit(client *Client) {
+	if _, ok := r.pending[client]; !ok {
+		// Already unregistered while WebRTC was connecting
+		return
+	}
+	delete(r.pending, client)
+
+	// Checked again in case the room filled up while this client was connecting
+	if !r.admitClient(client) {
+		r.print(fmt.Sprintf("rejected %s, room filled up", client.GetDisplayName()))
+		closeWithReason(client.ws, roomFullCloseCode, "room is full")
+		r.handleUnregister(client)
+		return
+	}
 
-			if len(r.incomingQueue) > 0 {
-				for _, imsg := range(r.incomingQueue) {
-					msg := Msg{}
-					err := Unpack(imsg.b, &msg)
-					if err != nil {
-						r.print(fmt.Sprintf("unpacking error: %v", err))
-						continue
-					}
-					r.processMsg(msg, imsg.client)
-				}
-				r.incomingQueue = r.incomingQueue[:0]
-			}
+	err := r.initClient(client)
+	if err != nil {
+		r.print(fmt.Sprintf("failed to init %s: %v", client.GetDisplayName(), err))
+		r.handleUnregister(client)
+		return
+	}
+	r.updateBots()
+	r.publishStatus()
+	r.updateDeleteTimer()
+}
+
+func (r *Room) handleUnregister(client *Client) {
+	delete(r.pending, client)
+	r.unregisterClient(client)
+	r.updateBots()
+	r.publishStatus()
+	r.updateDeleteTimer()
+}
+
+func (r *Room) handleIncoming(imsg IncomingMsg) {
+	msg := Msg{}
+	err := Unpack(imsg.b, &msg)
+	if err != nil {
+		r.print(fmt.Sprintf("unpacking error: %v", err))
+		return
+	}
+	r.processMsg(msg, imsg.client)
+}
+
+// Count down to deleting the room while no one is in it or connecting to it.
+func (r *Room) updateDeleteTimer() {
+	if len(r.clients) == 0 && len(r.pending) == 0 {
+		if !r.deleteTimer.Started() {
+			r.print("started countdown to delete room")
+			r.deleteTimer.Start(time.Now())
 		}
+	} else if r.deleteTimer.Started() {
+		r.print("stopping deletion due to reconnect")
+		r.deleteTimer.Stop()
 	}
 }
 
 func (r *Room) registerClient(client *Client) error {
+	r.assignClientId(client)
+	if !r.admitClient(client) {
+		r.print(fmt.Sprintf("rejected %s, room is full", client.GetDisplayName()))
+		closeWithReason(client.ws, roomFullCloseCode, "room is full")
+		return nil
+	}
+	if identity, ok := r.identities.Take(client.id, time.Now()); ok {
+		client.name = identity.name
+		client.identity = &identity
+	}
+	go client.run()
+	r.pending[client] = true
+
 	err := client.InitWebRTC(func() {
-		r.init <- client
+		select {
+		case r.init <- client:
+		case <-r.done:
+		}
 	})
 	if err != nil {
 		return err
@@ -190,6 +422,9 @@ func (r *Room) initClient(client *Client) error {
 	var err error
 
 	r.clients[client.id] = client
+	if r.owner == nil {
+		r.owner = client
+	}
 
 	err = r.updateClients(joinType, client)
 	if err != nil {
@@ -202,19 +437,22 @@ func (r *Room) initClient(client *Client) error {
 		return err
 	}
 
-	playerId := Id(playerSpace, client.id)
-	if !r.game.Has(playerId) {
-
-		player := r.game.Add(NewInit(playerId, NewVec2(0, 0), NewVec2(0.8, 1.44))).(*Player)
-		player.SetInitProp(nameProp, client.GetDisplayName())
-		player.SetTeam(0)
-		player.SetSpawn(r.game.GetGrid())
-		player.Respawn()
-	} else {
-		player := r.game.Get(playerId)
-		player.RemoveTTL()
-		r.print(fmt.Sprintf("%s reconnected", client.GetDisplayName()))
+	if !client.IsSpectator() {
+		player, reconnected := r.game.JoinPlayer(client.id, client.GetDisplayName())
+		if reconnected {
+			r.print(fmt.Sprintf("%s reconnected", client.GetDisplayName()))
+		} else if client.identity != nil {
+			client.identity.Restore(player)
+			r.print(fmt.Sprintf("restored %s", client.GetDisplayName()))
+		}
+		client.identity = nil
 	}
+	gameStateMsg := r.game.createGameStateMsg()
+	err = client.Send(&gameStateMsg)
+	if err != nil {
+		return err
+	}
+
 	playerInitMsg := r.game.createPlayerInitMsg(client.id)
 	err = client.Send(&playerInitMsg)
 	if err != nil {
@@ -231,22 +469,29 @@ func (r *Room) initClient(client *Client) error {
 		client.Send(&chatMsg)
 	}
 
+	if client.IsSpectator() {
+		r.cycleSpectateTarget(client, 0)
+	}
+
 	r.print(fmt.Sprintf("%s joined, total clients = %d", client.GetDisplayName(), len(r.clients)))
 	return nil
 }
 
 func (r *Room) unregisterClient(client *Client) error {
 	client.Close()
-	if _, ok := r.clients[client.id]; ok {
+	if existing, ok := r.clients[client.id]; ok && existing == client {
 		err := r.updateClients(leftType, client)
 		if err != nil {
 			return err
 		}
 		delete(r.clients, client.id)
 
-		player := r.game.Get(Id(playerSpace, client.id))
-		if player != nil {
-			player.SetConstantTTL(10 * time.Second)
+		if !client.IsSpectator() {
+			r.identities.Save(client.id, client.name, r.game.GetGrid().Get(Id(playerSpace, client.id)), time.Now())
+			r.game.LeavePlayer(client.id)
+		}
+		if r.owner == client {
+			r.transferOwner()
 		}
 	}
 	r.print(fmt.Sprintf("unregistered %s, total=%d", client.GetDisplayName(), len(r.clients)))
@@ -263,6 +508,10 @@ func (r* Room) processMsg(msg Msg, c* Client) error {
 			S: msg.Ping.S,
 		}
 		c.Send(&outMsg)
+	case serverPingType:
+		if ping, ok := c.pinger.Echo(msg.Ping.S, time.Now()); ok && !c.IsSpectator() && r.playback == nil {
+			r.game.SetPing(c.id, ping)
+		}
 	case offerType:
 		err = c.processWebRTCOffer(msg.JSON)
 	case candidateType:
@@ -276,10 +525,28 @@ func (r* Room) processMsg(msg Msg, c* Client) error {
 	case voiceAnswerType:
 		err = r.forwardVoiceMessage(msg.T, c, msg.JSONPeer)
 	case chatType:
+		if chatErr := r.chat.Allow(c, time.Now()); chatErr != nil {
+			reply := NewSystemChatMsg(chatErr.Error())
+			err = c.Send(&reply)
+			break
+		}
+		if IsCommand(msg.Chat.M) {
+			reply := r.processCommand(c, msg.Chat.M)
+			err = c.Send(&reply)
+			break
+		}
 		outMsg := r.chat.ProcessChatMsg(c, msg.Chat)
 		r.send(&outMsg)
 	case keyType:
-		r.game.ProcessKeyMsg(c.id, msg.Key)
+		if !c.IsSpectator() {
+			r.game.ProcessKeyMsg(c.id, msg.Key)
+		}
+	case ackType:
+		c.Ack(msg.Ack.S, r.game.seqNum)
+	case spectateType:
+		if c.IsSpectator() {
+			r.cycleSpectateTarget(c, msg.Spectate.D)
+		}
 	default:
 		r.print(fmt.Sprintf("unknown message type %d", msg.T))
 	}
@@ -290,10 +557,145 @@ func (r* Room) processMsg(msg Msg, c* Client) error {
 	return err
 }
 
+func (r Room) IsOwner(client *Client) bool {
+	return r.owner == client
+}
+
+func (r *Room) setOwner(client *Client) {
+	r.owner = client
+	r.announce(fmt.Sprintf("%s is now the room owner", client.GetDisplayName()))
+}
+
+// Hand the owner role to whoever has been here longest.
+func (r *Room) transferOwner() {
+	r.owner = nil
+
+	var next *Client
+	for id, client := range(r.clients) {
+		if next == nil || id < next.id {
+			next = client
+		}
+	}
+	if next != nil {
+		r.setOwner(next)
+	}
+}
+
+func (r Room) humanCount() int {
+	humans := 0
+	for _, client := range(r.clients) {
+		if !client.IsSpectator() {
+			humans += 1
+		}
+	}
+	return humans
+}
+
+// Add or remove bots so there are enough players, plus any requested from chat.
+func (r *Room) updateBots() {
+	if r.playback != nil {
+		return
+	}
+
+	humans := r.humanCount()
+	bots := r.game.GetBotIds()
+	target := 0
+	if humans > 0 {
+		target = IntMax(minRoomPlayers - humans, 0) + r.extraBots
+		target = IntMax(target, 0)
+	}
+
+	for i := len(bots); i < target; i += 1 {
+		id := r.nextClientId
+		r.nextClientId += 1
+		r.game.AddBot(id, fmt.Sprintf("bot %d", id))
+		r.print(fmt.Sprintf("added bot %d", id))
+	}
+
+	for i := len(bots) - 1; i >= target; i -= 1 {
+		r.game.RemoveBot(bots[i])
+		r.print(fmt.Sprintf("removed bot %d", bots[i]))
+	}
+}
+
+// Bots normally fill the room up to minRoomPlayers, so store the difference.
+func (r *Room) setBotCount(count int) {
+	r.extraBots = count - IntMax(minRoomPlayers - r.humanCount(), 0)
+	r.updateBots()
+}
+
+// Tell everyone the server is going away, then disconnect them.
+func (r *Room) shutdown() {
+	r.print("shutting down")
+
+	msg := ShutdownMsg {
+		T: shutdownType,
+		M: "Server is restarting, please reconnect in a bit.",
+	}
+	r.send(&msg)
+
+	for _, client := range(r.clients) {
+		closeWithReason(client.ws, websocket.CloseGoingAway, "server shutting down")
+		client.Close()
+	}
+	for client := range(r.pending) {
+		client.Close()
+	}
+}
+
+func (r *Room) saveReplay(replay *Replay) {
+	file, err := SaveReplay(replay)
+	if err != nil {
+		r.print(fmt.Sprintf("failed to save replay: %v", err))
+		return
+	}
+	if replay.Truncated {
+		r.print(fmt.Sprintf("saved replay to %s, dropped inputs past %d events", file, maxReplayEvents))
+	} else {
+		r.print(fmt.Sprintf("saved replay to %s", file))
+	}
+}
+
+func (r *Room) startPlayback(file string) error {
+	replay, err := LoadReplay(file)
+	if err != nil {
+		return err
+	}
+
+	r.playback = NewReplayPlayer(replay, r.game)
+	r.nextClientId = r.playback.MaxId() + 1
+	r.print(fmt.Sprintf("playing back %s from room %s", file, replay.Room))
+	return nil
+}
+
+// Move the spectator's camera to the next player in the given direction.
+func (r *Room) cycleSpectateTarget(c *Client, dir int) {
+	players := sortById(r.game.GetGrid().GetObjects(playerSpace))
+	if len(players) == 0 {
+		return
+	}
+
+	index := 0
+	for i, player := range(players) {
+		if player.GetId() == c.target {
+			index = Mod(i + dir, len(players))
+			break
+		}
+	}
+
+	c.target = players[index].GetId()
+	msg := SpectateMsg {
+		T: spectateType,
+		Id: c.target,
+	}
+	c.Send(&msg)
+}
+
 func (r *Room) updateClients(msgType MessageType, client *Client) error {
 	msg := r.createClientMsg(msgType, client, false)
 
 	if msgType == initType {
+		msg.Token = r.reconnectToken(client.id)
 		return client.Send(&msg)
 	} else {
 		r.send(&msg)
@@ -355,6 +757,20 @@ func (r *Room) send(msg interface{}) {
 	}
 }
 
+// Players echo these back so the room can measure their ping itself.
+func (r *Room) sendPings(now time.Time) {
+	for _, c := range(r.clients) {
+		if c.IsSpectator() {
+			continue
+		}
+		msg := PingMsg {
+			T: serverPingType,
+			S: c.pinger.Next(now),
+		}
+		c.Send(&msg)
+	}
+}
+
 func (r *Room) sendGameState(updates map[GameUpdateType]bool) {
 	if update, ok := updates[levelGameUpdate]; ok && update {
 		level := r.game.createLevelInitMsg()
@@ -367,8 +783,22 @@ func (r *Room) sendGameState(updates map[GameUpdateType]bool) {
 	}
 
 	if update, ok := updates[objectGameUpdate]; ok && update {
-		state := r.game.createObjectDataMsg()
-		r.sendUDP(&state)
+		r.game.updateSnapshot()
+		for _, c := range(r.clients) {
+			state := r.game.createObjectDataMsg(c.GetAck())
+			if c.GetCodec() != compactCodec {
+				c.SendUDP(&state)
+				continue
+			}
+
+			compact := NewCompactObjectStateMsg(state)
+			b := Pack(&compact)
+			c.SendBytesUDP(b)
+			if r.sampleCodec {
+				r.codecStats.Add(state, b)
+				r.sampleCodec = false
+			}
+		}
 
 		if updates, ok := r.game.createObjectUpdateMsg(); ok {
 			r.send(&updates)
@@ -376,13 +806,6 @@ func (r *Room) sendGameState(updates map[GameUpdateType]bool) {
 	}
 }
 
-func (r *Room) sendUDP(msg interface{}) {
-	b := Pack(msg)
-	for _, c := range(r.clients) {
-		c.SendBytesUDP(b)
-	}
-}
-
 func (r Room) print(message string) {
    	var sb strings.Builder
    	sb.WriteString(r.name)
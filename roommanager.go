@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"github.com/gorilla/websocket"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errWrongPassword = errors.New("wrong password")
+
+// Owns every running room. Rooms are created from HTTP handler goroutines and deleted from
+// their own goroutine, so all access to the map goes through here.
+type RoomManager struct {
+	mu sync.Mutex
+	rooms map[string]*Room
+	// Copied from each room when it's created so joins can be checked without touching the room
+	settings map[string]RoomSettings
+	wg sync.WaitGroup
+	shuttingDown bool
+}
+
+var roomManager = NewRoomManager()
+
+func NewRoomManager() *RoomManager {
+	return &RoomManager {
+		rooms: make(map[string]*Room),
+		settings: make(map[string]RoomSettings),
+		shuttingDown: false,
+	}
+}
+
+func (rm *RoomManager) Get(name string) (*Room, bool) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	r, ok := rm.rooms[name]
+	return r, ok
+}
+
+func (rm *RoomManager) CreateOrJoin(vars map[string]string, ws *websocket.Conn) {
+	requestedId := -1
+	if id, err := strconv.Atoi(vars["id"]); err == nil {
+		requestedId = id
+	}
+
+	for {
+		r, err := rm.getOrCreate(vars)
+		if err == errWrongPassword {
+			log.Printf("Rejected %s from room %s: %v", vars["name"], vars["room"], err)
+			closeWithReason(ws, wrongPasswordCloseCode, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to create room %s: %v", vars["room"], err)
+			ws.Close()
+			return
+		}
+		if r == nil {
+			closeWithReason(ws, websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+
+		client := NewClient(r, ws, vars["name"], requestedId)
+		client.SetSpectator(vars["spectate"] == "1" || r.playback != nil)
+		client.SetReconnectToken(vars["token"])
+		if codec, ok := codecNames[vars["codec"]]; ok {
+			client.SetCodec(codec)
+		}
+
+		select {
+		case r.register <- client:
+			return
+		case <-r.done:
+			// The room closed before it saw us, so try again with a new one
+		}
+	}
+}
+
+// Returns nil if the server is shutting down. New rooms are always joinable since the client
+// is creating them. Capacity is checked by the room itself when the client registers.
+func (rm *RoomManager) getOrCreate(vars map[string]string) (*Room, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.shuttingDown {
+		return nil, nil
+	}
+
+	name := vars["room"]
+	if r, ok := rm.rooms[name]; ok {
+		if !rm.settings[name].CheckPassword(vars["password"]) {
+			return nil, errWrongPassword
+		}
+		return r, nil
+	}
+
+	r := NewRoom(name, vars)
+	if file, ok := vars["replay"]; ok {
+		err := r.startPlayback(file)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		r.game.SetSeed(time.Now().UnixNano())
+		r.game.StartRecording(name)
+		r.game.LoadLevel(lobbyLevel, 0)
+	}
+
+	rm.rooms[name] = r
+	rm.settings[name] = r.settings
+	rm.wg.Add(1)
+	go func() {
+		defer rm.wg.Done()
+		r.run()
+	}()
+	return r, nil
+}
+
+// Called by the room when its delete timer runs out. Returns false if someone joined in the meantime.
+func (rm *RoomManager) deleteIfEmpty(r *Room) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if len(r.clients) > 0 || len(r.pending) > 0 {
+		return false
+	}
+	if rm.rooms[r.name] == r {
+		delete(rm.rooms, r.name)
+		delete(rm.settings, r.name)
+	}
+	return true
+}
+
+// Stop accepting new rooms, tell every room to disconnect its clients and wait for them to exit.
+func (rm *RoomManager) Shutdown(timeout time.Duration) bool {
+	rm.mu.Lock()
+	rm.shuttingDown = true
+	for name, r := range(rm.rooms) {
+		close(r.quit)
+		delete(rm.rooms, name)
+		delete(rm.settings, name)
+	}
+	rm.mu.Unlock()
+
+	done := make(chan bool)
+	go func() {
+		rm.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
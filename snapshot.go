@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+)
+
+const (
+	// Clients that haven't acked in this many frames get a full snapshot
+	maxSnapshotDelay SeqNumType = 60
+)
+
+// Remembers the frame each prop last changed so clients only get what changed since their last ack.
+type SnapshotTracker struct {
+	last ObjectPropMap
+	changed map[SpaceType]map[IdType]map[Prop]SeqNumType
+}
+
+func NewSnapshotTracker() *SnapshotTracker {
+	return &SnapshotTracker {
+		last: make(ObjectPropMap),
+		changed: make(map[SpaceType]map[IdType]map[Prop]SeqNumType),
+	}
+}
+
+func (st *SnapshotTracker) Update(seqNum SeqNumType, objects ObjectPropMap) {
+	changed := make(map[SpaceType]map[IdType]map[Prop]SeqNumType)
+
+	for space, spacedObjects := range(objects) {
+		changed[space] = make(map[IdType]map[Prop]SeqNumType)
+
+		for id, props := range(spacedObjects) {
+			lastProps := st.last[space][id]
+			lastChanged := st.changed[space][id]
+
+			changed[space][id] = make(map[Prop]SeqNumType)
+			for prop, value := range(props) {
+				lastValue, ok := lastProps[prop]
+				if seq, seqOk := lastChanged[prop]; ok && seqOk && reflect.DeepEqual(value, lastValue) {
+					changed[space][id][prop] = seq
+				} else {
+					changed[space][id][prop] = seqNum
+				}
+			}
+		}
+	}
+
+	st.last = objects
+	st.changed = changed
+}
+
+// Returns only the props that changed after the baseline along with the baseline used,
+// or everything and a baseline of 0 if the client is too far behind.
+func (st SnapshotTracker) Delta(seqNum SeqNumType, baseline SeqNumType) (ObjectPropMap, SeqNumType) {
+	if baseline == 0 || baseline > seqNum || seqNum - baseline > maxSnapshotDelay {
+		return st.last, 0
+	}
+
+	delta := make(ObjectPropMap)
+	for space, spacedObjects := range(st.last) {
+		for id, props := range(spacedObjects) {
+			changedProps := make(PropMap)
+			for prop, value := range(props) {
+				if st.changed[space][id][prop] > baseline {
+					changedProps[prop] = value
+				}
+			}
+
+			if len(changedProps) == 0 {
+				continue
+			}
+			if _, ok := delta[space]; !ok {
+				delta[space] = make(SpacedPropMap)
+			}
+			delta[space][id] = changedProps
+		}
+	}
+	return delta, baseline
+}
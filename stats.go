@@ -0,0 +1,120 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// Damage within this long before a death counts towards an assist
+	assistTime time.Duration = lastDamageTime
+)
+
+// Weapon credited for damage done by each kind of projectile
+var projectileWeapons = map[SpaceType]EquipType {
+	pelletSpace: uziWeapon,
+	rocketSpace: bazookaWeapon,
+	boltSpace: sniperWeapon,
+	starSpace: starWeapon,
+	grapplingHookSpace: grapplingHookWeapon,
+}
+
+// Sent in the scoreboard at the end of every round
+type PlayerStats struct {
+	Name string
+	Team uint8
+	Kills int
+	Deaths int
+	Assists int
+	Damage map[EquipType]int
+	Escorts int
+	Wins int
+}
+
+func NewPlayerStats() *PlayerStats {
+	return &PlayerStats {
+		Damage: make(map[EquipType]int),
+	}
+}
+
+// Stats for everyone who played since the match left the lobby. Entries are kept when players
+// leave so the scoreboard still has them.
+type MatchStats struct {
+	players map[IdType]*PlayerStats
+}
+
+func NewMatchStats() *MatchStats {
+	return &MatchStats {
+		players: make(map[IdType]*PlayerStats),
+	}
+}
+
+func (ms *MatchStats) Reset() {
+	ms.players = make(map[IdType]*PlayerStats)
+}
+
+// Returns the entry for the player, refreshing the name and team from the object.
+func (ms *MatchStats) get(player Object) *PlayerStats {
+	stats, ok := ms.players[player.GetId()]
+	if !ok {
+		stats = NewPlayerStats()
+		ms.players[player.GetId()] = stats
+	}
+
+	if name, ok := player.GetInitData().Get(nameProp).(string); ok {
+		stats.Name = name
+	}
+	stats.Team, _ = player.GetByteAttribute(teamByteAttribute)
+	return stats
+}
+
+func (ms *MatchStats) RecordDamage(attacker Object, victim Object, weapon EquipType, damage int) {
+	if attacker == nil || damage <= 0 || attacker.GetSpace() != playerSpace || attacker.GetSpacedId() == victim.GetSpacedId() {
+		return
+	}
+	ms.get(attacker).Damage[weapon] += damage
+	ms.get(victim)
+}
+
+// Everyone else who damaged the victim recently gets an assist.
+func (ms *MatchStats) RecordDeath(g *Grid, victim Object, killer SpacedId, ticks []DamageTick) {
+	ms.get(victim).Deaths += 1
+
+	if object := g.Get(killer); object != nil && killer != victim.GetSpacedId() {
+		ms.get(object).Kills += 1
+	}
+
+	assisted := make(map[SpacedId]bool)
+	for _, tick := range(ticks) {
+		if tick.sid == killer || tick.sid == victim.GetSpacedId() || assisted[tick.sid] {
+			continue
+		}
+		assisted[tick.sid] = true
+
+		if object := g.Get(tick.sid); object != nil && object.GetSpace() == playerSpace {
+			ms.get(object).Assists += 1
+		}
+	}
+}
+
+func (ms *MatchStats) RecordEscort(vip Object) {
+	ms.get(vip).Escorts += 1
+}
+
+func (ms *MatchStats) RecordWin(team uint8, players map[IdType]Object) {
+	if team == 0 {
+		return
+	}
+	for _, player := range(players) {
+		if playerTeam, ok := player.GetByteAttribute(teamByteAttribute); ok && playerTeam == team {
+			ms.get(player).Wins += 1
+		}
+	}
+}
+
+func (ms MatchStats) Scoreboard() map[IdType]PlayerStats {
+	scoreboard := make(map[IdType]PlayerStats, len(ms.players))
+	for id, stats := range(ms.players) {
+		scoreboard[id] = *stats
+	}
+	return scoreboard
+}
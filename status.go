@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	roomsEndpoint string = "/bd3/rooms/"
+	statusEndpoint string = "/bd3/status"
+)
+
+var gameStateNames = map[GameStateType]string {
+	lobbyGameState: "lobby",
+	setupGameState: "setup",
+	activeGameState: "active",
+	victoryGameState: "victory",
+}
+
+type PlayerStatus struct {
+	Id IdType
+	Name string
+	Team uint8
+	Kills int
+	Deaths int
+	Bot bool
+}
+
+type RoomStatus struct {
+	Name string
+	Players int
+	Spectators int
+	Bots int
+	MaxPlayers int
+	Password bool
+	State string
+	StateId GameStateType
+	Mode string
+	Level string
+	LevelId LevelIdType
+	Scores map[uint8]int
+	Uptime int64 // seconds
+	Replay bool
+	Load float64 // fraction of a core used by the room loop
+	TickJitterMs float64
+
+	private bool
+}
+
+type RoomDetail struct {
+	RoomStatus
+	PlayerList []PlayerStatus
+}
+
+type ServerStatus struct {
+	Version string
+	Rooms int
+	Players int
+	Uptime int64 // seconds
+}
+
+// Rooms publish their status here so HTTP handlers never touch a running room.
+type RoomStatuses struct {
+	mu sync.RWMutex
+	rooms map[string]RoomDetail
+	start time.Time
+}
+
+var roomStatuses = NewRoomStatuses()
+
+func NewRoomStatuses() *RoomStatuses {
+	return &RoomStatuses {
+		rooms: make(map[string]RoomDetail),
+		start: time.Now(),
+	}
+}
+
+func (rs *RoomStatuses) Set(detail RoomDetail) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.rooms[detail.Name] = detail
+}
+
+func (rs *RoomStatuses) Delete(name string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.rooms, name)
+}
+
+func (rs *RoomStatuses) Get(name string) (RoomDetail, bool) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	detail, ok := rs.rooms[name]
+	return detail, ok
+}
+
+// Private rooms are left out, but can still be looked up by name.
+func (rs *RoomStatuses) List() []RoomStatus {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	list := make([]RoomStatus, 0, len(rs.rooms))
+	for _, detail := range(rs.rooms) {
+		if detail.private {
+			continue
+		}
+		list = append(list, detail.RoomStatus)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (rs *RoomStatuses) GetServerStatus() ServerStatus {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	status := ServerStatus {
+		Version: gameVersion,
+		Rooms: len(rs.rooms),
+		Uptime: int64(time.Since(rs.start) / time.Second),
+	}
+	for _, detail := range(rs.rooms) {
+		status.Players += detail.Players
+	}
+	return status
+}
+
+func roomsEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(r.URL.Path[len(roomsEndpoint):], "/")
+	if name == "" {
+		writeJSON(w, r, roomStatuses.List())
+		return
+	}
+
+	detail, ok := roomStatuses.Get(name)
+	if !ok {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, r, detail)
+}
+
+func statusEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, roomStatuses.GetServerStatus())
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	if origin := r.Header.Get("Origin"); allowedOrigins[origin] {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Failed to write JSON: %v", err)
+	}
+}
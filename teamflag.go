@@ -0,0 +1,137 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	flagReturnTime time.Duration = 10 * time.Second
+	flagCarryOffsetY float64 = 1.0
+)
+
+type TeamFlag struct {
+	BaseObject
+
+	home Vec2
+	carrier SpacedId
+}
+
+func NewTeamFlag(init Init) *TeamFlag {
+	f := &TeamFlag {
+		BaseObject: NewRec2Object(init),
+		home: init.InitPos(),
+		carrier: InvalidId(),
+	}
+
+	overlapOptions := NewColliderOptions()
+	overlapOptions.SetSpaces(playerSpace)
+	overlapOptions.SetAttributes(deadAttribute)
+	f.SetOverlapOptions(overlapOptions)
+
+	return f
+}
+
+func (f *TeamFlag) SetTeam(team uint8) {
+	f.SetByteAttribute(teamByteAttribute, team)
+	f.SetIntAttribute(colorIntAttribute, TeamColor(team))
+}
+
+func (f TeamFlag) GetTeam() uint8 {
+	team, _ := f.GetByteAttribute(teamByteAttribute)
+	return team
+}
+
+func (f TeamFlag) GetCarrier() SpacedId {
+	return f.carrier
+}
+
+func (f TeamFlag) AtHome() bool {
+	return f.carrier.Invalid() && f.Pos().ApproxEq(f.home)
+}
+
+func (f *TeamFlag) Return(grid *Grid) {
+	f.drop(grid)
+	f.RemoveTTL()
+	f.SetPos(f.home)
+	grid.Upsert(f)
+}
+
+func (f *TeamFlag) Update(grid *Grid, now time.Time) {
+	if isWasm {
+		return
+	}
+
+	f.PrepareUpdate(now)
+	f.BaseObject.Update(grid, now)
+
+	if f.carrier.Valid() {
+		// Captured flags are expired by the game mode
+		if f.Expired(now) {
+			f.Return(grid)
+			return
+		}
+
+		carrier := grid.Get(f.carrier)
+		if carrier == nil || carrier.HasAttribute(deadAttribute) || carrier.HasAttribute(deletedAttribute) {
+			f.drop(grid)
+			f.SetConstantTTL(flagReturnTime, now)
+		}
+		return
+	}
+
+	if f.Expired(now) || f.Pos().Y < -7 {
+		f.Return(grid)
+		return
+	}
+
+	colliders := grid.GetColliders(f)
+	for len(colliders) > 0 {
+		collider := PopObject(&colliders)
+		player, ok := collider.(*Player)
+		if !ok {
+			continue
+		}
+
+		team, _ := player.GetByteAttribute(teamByteAttribute)
+		if team == 0 {
+			continue
+		}
+
+		if team == f.GetTeam() {
+			if !f.AtHome() {
+				f.Return(grid)
+				return
+			}
+		} else if !player.HasAttribute(carryingAttribute) {
+			f.pickUp(player)
+			return
+		}
+	}
+}
+
+func (f *TeamFlag) OnDelete(grid *Grid) {
+	f.drop(grid)
+}
+
+func (f *TeamFlag) pickUp(player *Player) {
+	f.carrier = player.GetSpacedId()
+	f.RemoveTTL()
+	f.Stop()
+	f.AddConnection(f.carrier, NewOffsetConnection(NewVec2(0, flagCarryOffsetY)))
+	player.AddAttribute(carryingAttribute)
+}
+
+func (f *TeamFlag) drop(grid *Grid) {
+	if f.carrier.Invalid() {
+		return
+	}
+
+	if carrier := grid.Get(f.carrier); carrier != nil {
+		carrier.RemoveAttribute(carryingAttribute)
+	}
+
+	f.removeConnection(f.carrier)
+	f.RemoveAttribute(attachedAttribute)
+	f.carrier = InvalidId()
+	f.Stop()
+}
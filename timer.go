@@ -29,8 +29,8 @@ func (t *Timer) SetDuration(duration time.Duration) {
 	t.duration = duration
 }
 
-func (t *Timer) Start() {
-	t.startTime = time.Now()
+func (t *Timer) Start(now time.Time) {
+	t.startTime = now
 	t.started = true
 }
 
@@ -42,39 +42,39 @@ func (t Timer) Started() bool {
 	return t.started
 }
 
-func (t Timer) On() bool {
+func (t Timer) On(now time.Time) bool {
 	if !t.started {
 		return false
 	}
 
-	elapsed := t.Elapsed()
+	elapsed := t.Elapsed(now)
 	return 0 <= elapsed && elapsed <= t.duration
 }
 
-func (t Timer) Finished() bool {
+func (t Timer) Finished(now time.Time) bool {
 	if !t.started {
 		return false
 	}
 
-	return t.Elapsed() > t.duration
+	return t.Elapsed(now) > t.duration
 }
 
-func (t Timer) Elapsed() time.Duration {
+func (t Timer) Elapsed(now time.Time) time.Duration {
 	if !t.started {
 		return 0
 	}
 
-	elapsed := time.Now().Sub(t.startTime.Add(t.delay))
+	elapsed := now.Sub(t.startTime.Add(t.delay))
 	return elapsed
 }
 
 
-func (t Timer) Lerp(min float64, max float64) float64 {
+func (t Timer) Lerp(now time.Time, min float64, max float64) float64 {
 	if !t.started {
 		return min
 	}
 
-	ts := Max(float64(t.Elapsed()), 0) / float64(t.duration)
+	ts := Max(float64(t.Elapsed(now)), 0) / float64(t.duration)
 
 	return min + ts * (max - min)
 }
\ No newline at end of file
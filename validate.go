@@ -0,0 +1,564 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+const (
+	// Distance between the standing spots sampled along the top of a wall
+	validateSpacing float64 = 2.0
+	// Width of the columns walls are bucketed into for line checks
+	validateColumnWidth float64 = 4.0
+
+	// Roughly the player's size, see Game.newPlayer
+	validateBodyWidth float64 = 0.8
+	validateBodyHeight float64 = 1.44
+
+	// Moves are traced this far above the floor so they don't graze it
+	validateLift float64 = 0.5
+	// Height that can be walked up without jumping
+	validateStepHeight float64 = 0.5
+	// Headroom needed above the higher end of a jump
+	validateJumpClearance float64 = 1.0
+	// Don't trace jumps that fall further than this
+	validateMaxFall float64 = 30.0
+	// Snapping pushes players up onto ledges they barely miss
+	validateLedgeMargin float64 = 0.3
+
+	validateEpsilon float64 = 0.01
+)
+
+// Path of a running jump with a double jump at the peak, relative to where it started.
+var jumpArc = simulateJumpArc()
+
+func simulateJumpArc() []Vec2 {
+	ts := float64(frameMillis) / 1000
+	pos := NewVec2(0, 0)
+	vel := NewVec2(maxHorizontalVel, jumpVel)
+	jumpTime := jumpDuration.Seconds()
+	doubleJumped := false
+
+	arc := []Vec2{pos}
+	for pos.Y > -validateMaxFall {
+		acc := gravityAcc
+		if jumpTime <= 0 || vel.Y <= 0 {
+			acc += downAcc
+		}
+		vel.Y += acc * ts
+		jumpTime -= ts
+
+		if !doubleJumped && vel.Y <= 0 {
+			vel.Y = jumpVel
+			jumpTime = jumpDuration.Seconds()
+			doubleJumped = true
+		}
+		if vel.Y < maxDownwardVel {
+			vel.Y *= maxVelMultiplier
+		}
+
+		pos.Add(vel, ts)
+		arc = append(arc, pos)
+	}
+	return arc
+}
+
+func jumpHeight() float64 {
+	height := 0.0
+	for _, point := range(jumpArc) {
+		height = Max(height, point.Y)
+	}
+	return height + validateLedgeMargin
+}
+
+// Whether a jump can land dx away horizontally and dy higher.
+func canJump(dx float64, dy float64) bool {
+	for _, point := range(jumpArc) {
+		if point.Y + validateLedgeMargin >= dy && point.X >= dx {
+			return true
+		}
+	}
+	return false
+}
+
+type validateBox struct {
+	min Vec2
+	max Vec2
+	platform bool
+}
+
+func (vb validateBox) contains(point Vec2) bool {
+	return point.X > vb.min.X + validateEpsilon && point.X < vb.max.X - validateEpsilon &&
+		point.Y > vb.min.Y + validateEpsilon && point.Y < vb.max.Y - validateEpsilon
+}
+
+// Slab test against the box. Touching counts so lines can't slip between walls that meet.
+func (vb validateBox) intersects(from Vec2, to Vec2) bool {
+	tmin := 0.0
+	tmax := 1.0
+	for _, axis := range([][4]float64{
+		{from.X, to.X, vb.min.X, vb.max.X},
+		{from.Y, to.Y, vb.min.Y, vb.max.Y},
+	}) {
+		origin, d, low, high := axis[0], axis[1] - axis[0], axis[2], axis[3]
+		if Abs(d) < validateEpsilon {
+			if origin < low || origin > high {
+				return false
+			}
+			continue
+		}
+
+		t1 := (low - origin) / d
+		t2 := (high - origin) / d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tmin = Max(tmin, t1)
+		tmax = Min(tmax, t2)
+		if tmin > tmax {
+			return false
+		}
+	}
+	return true
+}
+
+// Somewhere a player can stand, on top of a wall
+type validateNode struct {
+	pos Vec2
+	wall int
+}
+
+// Builds a walking and jumping graph from the walls in the grid.
+type LevelValidator struct {
+	grid *Grid
+	boxes []validateBox
+	columns map[int][]int
+	nodes []validateNode
+	edges [][]int
+}
+
+func NewLevelValidator(grid *Grid) *LevelValidator {
+	lv := &LevelValidator {
+		grid: grid,
+		boxes: make([]validateBox, 0),
+		columns: make(map[int][]int),
+		nodes: make([]validateNode, 0),
+	}
+	lv.addWalls()
+	lv.addNodes()
+	lv.addEdges()
+	return lv
+}
+
+func (lv *LevelValidator) column(x float64) int {
+	return int(math.Floor(x / validateColumnWidth))
+}
+
+func (lv *LevelValidator) addWalls() {
+	for _, wall := range(sortById(lv.grid.GetObjects(wallSpace))) {
+		box := validateBox {
+			min: wall.PosC(bottomLeftCardinal),
+			max: wall.PosC(topRightCardinal),
+		}
+		if wallType, ok := wall.GetByteAttribute(typeByteAttribute); ok && WallType(wallType) == platformWall {
+			box.platform = true
+		}
+
+		index := len(lv.boxes)
+		lv.boxes = append(lv.boxes, box)
+		for col := lv.column(box.min.X); col <= lv.column(box.max.X); col += 1 {
+			lv.columns[col] = append(lv.columns[col], index)
+		}
+	}
+}
+
+// Sample spots along the top of every wall that have room for a player.
+func (lv *LevelValidator) addNodes() {
+	for i, box := range(lv.boxes) {
+		left := box.min.X + validateBodyWidth / 2
+		right := box.max.X - validateBodyWidth / 2
+
+		xs := []float64{(box.min.X + box.max.X) / 2}
+		if right > left {
+			count := IntUp((right - left) / validateSpacing)
+			xs = make([]float64, 0, count + 1)
+			for j := 0; j <= count; j += 1 {
+				xs = append(xs, left + float64(j) * (right - left) / float64(count))
+			}
+		}
+
+		for _, x := range(xs) {
+			pos := NewVec2(x, box.max.Y)
+			if lv.blocked(NewVec2(x, pos.Y + validateLift)) || lv.blocked(NewVec2(x, pos.Y + validateBodyHeight)) {
+				continue
+			}
+			lv.nodes = append(lv.nodes, validateNode {
+				pos: pos,
+				wall: i,
+			})
+		}
+	}
+}
+
+func (lv *LevelValidator) blocked(point Vec2) bool {
+	for _, index := range(lv.columns[lv.column(point.X)]) {
+		box := lv.boxes[index]
+		if !box.platform && box.contains(point) {
+			return true
+		}
+	}
+	return false
+}
+
+// Platforms can be jumped through, so only solid walls get in the way.
+func (lv *LevelValidator) hitsWall(from Vec2, to Vec2) bool {
+	first := lv.column(Min(from.X, to.X))
+	last := lv.column(Max(from.X, to.X))
+	for col := first; col <= last; col += 1 {
+		for _, index := range(lv.columns[col]) {
+			box := lv.boxes[index]
+			if !box.platform && box.intersects(from, to) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Walk straight across, or go up, over and down to approximate a jump.
+func (lv *LevelValidator) canMove(from Vec2, to Vec2, maxHeight float64) bool {
+	start := NewVec2(from.X, from.Y + validateLift)
+	end := NewVec2(to.X, to.Y + validateLift)
+
+	if Abs(to.Y - from.Y) <= validateStepHeight && !lv.hitsWall(start, end) {
+		return true
+	}
+
+	// Like start and end, the peak is traced above where the feet are
+	peak := Min(Max(from.Y, to.Y) + validateJumpClearance, from.Y + maxHeight)
+	peak = Max(peak, to.Y) + validateLift
+	up := NewVec2(from.X, peak)
+	over := NewVec2(to.X, peak)
+	return !lv.hitsWall(start, up) && !lv.hitsWall(up, over) && !lv.hitsWall(over, end)
+}
+
+// Connect each spot to the closest reachable spot on every other wall, and its neighbors on the same wall.
+func (lv *LevelValidator) addEdges() {
+	maxHeight := jumpHeight()
+	lv.edges = make([][]int, len(lv.nodes))
+
+	for i, node := range(lv.nodes) {
+		candidates := make(map[int][]int)
+		for j, other := range(lv.nodes) {
+			if i == j {
+				continue
+			}
+			dx := Abs(other.pos.X - node.pos.X)
+			dy := other.pos.Y - node.pos.Y
+			if dy > maxHeight || !canJump(dx, dy) {
+				continue
+			}
+			candidates[other.wall] = append(candidates[other.wall], j)
+		}
+
+		for wall, indices := range(candidates) {
+			sort.Slice(indices, func(a, b int) bool {
+				return node.pos.DistanceSquared(lv.nodes[indices[a]].pos) < node.pos.DistanceSquared(lv.nodes[indices[b]].pos)
+			})
+
+			for _, j := range(indices) {
+				if !lv.canMove(node.pos, lv.nodes[j].pos, maxHeight) {
+					continue
+				}
+				lv.edges[i] = append(lv.edges[i], j)
+				if wall != node.wall {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Shortest path lengths from the starting spots to every other spot.
+func (lv *LevelValidator) distances(starts []int) []float64 {
+	dist := make([]float64, len(lv.nodes))
+	done := make([]bool, len(lv.nodes))
+	for i := range(dist) {
+		dist[i] = math.Inf(1)
+	}
+	for _, start := range(starts) {
+		dist[start] = 0
+	}
+
+	for {
+		current := -1
+		for i := range(dist) {
+			if !done[i] && !math.IsInf(dist[i], 1) && (current < 0 || dist[i] < dist[current]) {
+				current = i
+			}
+		}
+		if current < 0 {
+			return dist
+		}
+
+		done[current] = true
+		for _, next := range(lv.edges[current]) {
+			d := dist[current] + lv.nodes[current].pos.Distance(lv.nodes[next].pos)
+			if d < dist[next] {
+				dist[next] = d
+			}
+		}
+	}
+}
+
+// Spots a player lands on after spawning, which is the highest floor under the spawn.
+func (lv *LevelValidator) spawnNodes(spawn Object) []int {
+	left := spawn.PosC(leftCardinal).X
+	right := spawn.PosC(rightCardinal).X
+	top := spawn.Pos().Y
+
+	floor := math.Inf(-1)
+	for _, node := range(lv.nodes) {
+		if node.pos.X >= left && node.pos.X <= right && node.pos.Y <= top {
+			floor = Max(floor, node.pos.Y)
+		}
+	}
+
+	nodes := make([]int, 0)
+	for i, node := range(lv.nodes) {
+		if node.pos.X >= left && node.pos.X <= right && Abs(node.pos.Y - floor) <= validateStepHeight {
+			nodes = append(nodes, i)
+		}
+	}
+	return nodes
+}
+
+// Spots where a player standing would touch the object.
+func (lv *LevelValidator) targetNodes(target Object) []int {
+	min := target.PosC(bottomLeftCardinal)
+	max := target.PosC(topRightCardinal)
+
+	// Small targets can fit between the sampled spots
+	min.X -= validateSpacing / 2
+	max.X += validateSpacing / 2
+
+	nodes := make([]int, 0)
+	for i, node := range(lv.nodes) {
+		if node.pos.X >= min.X && node.pos.X <= max.X && node.pos.Y >= min.Y - validateBodyHeight && node.pos.Y <= max.Y {
+			nodes = append(nodes, i)
+		}
+	}
+	return nodes
+}
+
+// Shortest path from a spawn to a goal, portal or another spawn
+type LevelPath struct {
+	From SpacedId
+	FromTeam uint8
+	To SpacedId
+	ToTeam uint8
+
+	// Infinite if there's no way there
+	Distance float64
+	Straight float64
+}
+
+func (lp LevelPath) Reachable() bool {
+	return !math.IsInf(lp.Distance, 1)
+}
+
+// How much longer the path is than a straight line
+func (lp LevelPath) Detour() float64 {
+	if lp.Straight <= 0 {
+		return 1
+	}
+	return lp.Distance / lp.Straight
+}
+
+type LevelReport struct {
+	Id LevelIdType
+	Seed LevelSeedType
+	Paths []LevelPath
+	Problems []string
+}
+
+func (lr LevelReport) Ok() bool {
+	return len(lr.Problems) == 0
+}
+
+func (lr LevelReport) Summary() string {
+	if lr.Ok() {
+		return fmt.Sprintf("%s seed %d: ok", LevelName(lr.Id), lr.Seed)
+	}
+	return fmt.Sprintf("%s seed %d: %s", LevelName(lr.Id), lr.Seed, strings.Join(lr.Problems, "; "))
+}
+
+// Summary followed by every path that was checked
+func (lr LevelReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(lr.Summary())
+	for _, path := range(lr.Paths) {
+		distance := "unreachable"
+		if path.Reachable() {
+			distance = fmt.Sprintf("%.1f (%.2fx straight)", path.Distance, path.Detour())
+		}
+		sb.WriteString(fmt.Sprintf("\n  team %d spawn %d -> team %d %s %d: %s",
+			path.FromTeam, path.From.Id, path.ToTeam, spaceName(path.To.S), path.To.Id, distance))
+	}
+	return sb.String()
+}
+
+func spaceName(space SpaceType) string {
+	switch (space) {
+	case goalSpace:
+		return "goal"
+	case portalSpace:
+		return "portal"
+	case spawnSpace:
+		return "spawn"
+	}
+	return "object"
+}
+
+// Every goal and portal should be reachable from every spawn, teams should be able to reach
+// each other, and no team should have a much more roundabout path to a goal than another.
+func (lv *LevelValidator) Validate(id LevelIdType, seed LevelSeedType, maxAsymmetry float64) LevelReport {
+	report := LevelReport {
+		Id: id,
+		Seed: seed,
+		Paths: make([]LevelPath, 0),
+		Problems: make([]string, 0),
+	}
+
+	spawns := sortById(lv.grid.GetObjects(spawnSpace))
+	targets := append(sortById(lv.grid.GetObjects(goalSpace)), sortById(lv.grid.GetObjects(portalSpace))...)
+
+	// Shortest detour to each goal by team
+	detours := make(map[IdType]map[uint8]float64)
+
+	for _, spawn := range(spawns) {
+		team, _ := spawn.GetByteAttribute(teamByteAttribute)
+		starts := lv.spawnNodes(spawn)
+		if len(starts) == 0 {
+			report.Problems = append(report.Problems, fmt.Sprintf("team %d spawn %d has no floor", team, spawn.GetId()))
+			continue
+		}
+		dist := lv.distances(starts)
+
+		destinations := make([]Object, 0, len(targets) + len(spawns))
+		destinations = append(destinations, targets...)
+		for _, other := range(spawns) {
+			if otherTeam, _ := other.GetByteAttribute(teamByteAttribute); otherTeam != team {
+				destinations = append(destinations, other)
+			}
+		}
+
+		for _, target := range(destinations) {
+			targetTeam, _ := target.GetByteAttribute(teamByteAttribute)
+			path := LevelPath {
+				From: spawn.GetSpacedId(),
+				FromTeam: team,
+				To: target.GetSpacedId(),
+				ToTeam: targetTeam,
+				Distance: math.Inf(1),
+				Straight: spawn.Pos().Distance(target.Pos()),
+			}
+
+			ends := lv.targetNodes(target)
+			if target.GetSpace() == spawnSpace {
+				ends = lv.spawnNodes(target)
+			}
+			for _, end := range(ends) {
+				path.Distance = Min(path.Distance, dist[end])
+
+				// Measure between floors so paths and straight lines are comparable
+				for _, start := range(starts) {
+					path.Straight = Min(path.Straight, lv.nodes[start].pos.Distance(lv.nodes[end].pos))
+				}
+			}
+			report.Paths = append(report.Paths, path)
+
+			if !path.Reachable() {
+				report.Problems = append(report.Problems, fmt.Sprintf("team %d spawn %d can't reach %s %d",
+					team, spawn.GetId(), spaceName(target.GetSpace()), target.GetId()))
+				continue
+			}
+			if target.GetSpace() != goalSpace || team == 0 {
+				continue
+			}
+
+			if _, ok := detours[target.GetId()]; !ok {
+				detours[target.GetId()] = make(map[uint8]float64)
+			}
+			if detour, ok := detours[target.GetId()][team]; !ok || path.Detour() < detour {
+				detours[target.GetId()][team] = path.Detour()
+			}
+		}
+	}
+
+	goals := make([]IdType, 0, len(detours))
+	for goal := range(detours) {
+		goals = append(goals, goal)
+	}
+	sort.Slice(goals, func(i, j int) bool { return goals[i] < goals[j] })
+
+	for _, goal := range(goals) {
+		shortest := math.Inf(1)
+		longest := 0.0
+		for _, detour := range(detours[goal]) {
+			shortest = Min(shortest, detour)
+			longest = Max(longest, detour)
+		}
+		if len(detours[goal]) > 1 && longest > maxAsymmetry * shortest {
+			report.Problems = append(report.Problems, fmt.Sprintf("paths to goal %d are lopsided (%.2fx vs %.2fx straight)", goal, longest, shortest))
+		}
+	}
+	return report
+}
+
+// Build the level in a scratch grid and check it.
+func ValidateLevel(id LevelIdType, seed LevelSeedType) (LevelReport, error) {
+	file, ok := levelFiles[id]
+	if !ok {
+		return LevelReport{}, fmt.Errorf("unknown level %d", id)
+	}
+
+	maxAsymmetry := defaultLevelMaxAsymmetry
+	if file.Validate != nil {
+		maxAsymmetry = file.Validate.MaxAsymmetry
+	}
+
+	grid := NewGrid(4, 4)
+	level := NewLevel()
+	level.load(file, seed, grid)
+	return NewLevelValidator(grid).Validate(id, seed, maxAsymmetry), nil
+}
+
+// Rerolls the seed until the level passes validation, and keeps the original if nothing does.
+func (lf LevelFile) ValidSeed(seed LevelSeedType) LevelSeedType {
+	if lf.Validate == nil {
+		return seed
+	}
+
+	r := rand.New(rand.NewSource(int64(seed)))
+	candidate := seed
+	for i := 0; i <= lf.Validate.Rerolls; i += 1 {
+		report, err := ValidateLevel(lf.Id, candidate)
+		if err != nil {
+			Log(fmt.Sprintf("Failed to validate %s: %v", lf.Name, err))
+			return seed
+		}
+		if report.Ok() {
+			return candidate
+		}
+
+		Log(fmt.Sprintf("Rerolling %s seed %d: %s", lf.Name, candidate, strings.Join(report.Problems, "; ")))
+		candidate = LevelSeedType(r.Uint32())
+	}
+
+	Log(fmt.Sprintf("No valid %s seed after %d rerolls, keeping %d", lf.Name, lf.Validate.Rerolls, seed))
+	return seed
+}
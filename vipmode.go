@@ -1,7 +1,6 @@
 package main
 
 import (
-	"math/rand"
 	"time"
 )
 
@@ -12,7 +11,6 @@ const (
 
 type VipMode struct {
 	BaseGameMode
-	random *rand.Rand
 
 	vip Object
 	nextVip map[uint8]int
@@ -21,14 +19,12 @@ type VipMode struct {
 
 func NewVipMode() *VipMode {
 	mode := &VipMode {
-		BaseGameMode: NewBaseGameMode(),
-		random: rand.New(rand.NewSource(UnixMilli())),
+		BaseGameMode: NewBaseGameMode(vipGameMode),
 
 		vip: nil,
 		nextVip: make(map[uint8]int),
 		restartTimer: NewTimer(3 * time.Second),
 	}
-	mode.SetState(lobbyGameState)
 	return mode
 }
 
@@ -41,22 +37,11 @@ func (vm *VipMode) Update(g *Grid) {
 
 	if vm.state == lobbyGameState {
 		if vm.firstFrame {
-			for _, player := range(g.GetObjects(playerSpace)) {
-				player.RemoveAttribute(vipAttribute)
-				player.AddInternalAttribute(autoRespawnAttribute)
-				player.SetByteAttribute(teamByteAttribute, 0)
-				player.SetIntAttribute(colorIntAttribute, teamColors[0])
-				player.(*Player).SetSpawn(g)
-				player.Respawn()
-			}
+			vm.resetPlayers(g)
 		}
 
-		vm.teams = make(map[uint8][]Object)
 		players := g.GetObjects(playerSpace)
-		for _, player := range(players) {
-			team, _ := player.GetByteAttribute(teamByteAttribute)
-			vm.teams[team] = append(vm.teams[team], player)
-		}
+		vm.updateTeams(players)
 
 		if len(vm.teams[0]) > 0 {
 			return
@@ -92,6 +77,7 @@ func (vm *VipMode) Update(g *Grid) {
 				team, _ := player.GetByteAttribute(teamByteAttribute)
 				vm.teams[team] = append(vm.teams[team], player)
 			}
+			vm.sortTeams()
 		}
 
 		if vm.firstFrame {
@@ -123,6 +109,11 @@ func (vm *VipMode) Update(g *Grid) {
 			return
 		}
 
+		// Only a goal sets the winner directly
+		if vm.winningTeam != 0 && vm.vip != nil {
+			g.GetStats().RecordEscort(vm.vip)
+		}
+
 		vm.winningTeam = vm.getWinningTeam()
 		if vm.winningTeam != 0 {
 			vm.teamScores[vm.winningTeam] += 1
@@ -130,10 +121,10 @@ func (vm *VipMode) Update(g *Grid) {
 		}
 	} else if vm.state == victoryGameState {
 		if vm.firstFrame {
-			vm.restartTimer.Start()
+			vm.restartTimer.Start(vm.now)
 			return
 		}
-		if vm.restartTimer.On() {
+		if vm.restartTimer.On(vm.now) {
 			return
 		}
 
@@ -252,11 +243,13 @@ func (vm VipMode) getWinningTeam() uint8 {
 }
 
 func (vm VipMode) getEnemyTeam(team uint8) uint8 {
-	if team <= 0 || team > vipModeTeams {
-		return 0
+	if team == vm.config.leftTeam {
+		return vm.config.rightTeam
 	}
-
-	return 3 - team
+	if team == vm.config.rightTeam {
+		return vm.config.leftTeam
+	}
+	return 0
 }
 
 func (vm *VipMode) swapSides(g *Grid) {
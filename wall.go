@@ -17,6 +17,7 @@ type WallSubtype uint8
 const (
 	unknownWallSubtype WallSubtype = iota
 	tableWallSubtype
+	jumpPadWallSubtype
 )
 
 type Wall struct {
@@ -1,9 +1,11 @@
-[string[]]$src_files = @("game.go", "association.go", "attachment.go", "attribute.go", "balconyblock.go", "block.go", "blockgrid.go", "booster.go", "cardinal.go", "chance.go", "collideroptions.go", "color.go", "circle.go", "data.go", "equip.go", "equipcharger.go", "expiration.go", "explosion.go", "flag.go", "gamemode.go", "grid.go", "health.go", "hutblock.go", "init.go", "initprops.go", "jetpack.go", "keys.go", "launcher.go", "level.go", "light.go", "log.go", "mainblock.go", "msg.go", "object.go", "objectheap.go", "objects.go", "optional.go", "player.go", "profile.go", "profilemath.go", "projectile.go", "projectiles.go", "rec2.go", "roofblock.go", "rotpoly.go", "state.go", "structs.go", "subprofile.go", "timer.go", "util.go", "vipmode.go", "wall.go", "weapon.go")
+[string[]]$src_files = @("game.go", "arena.go", "association.go", "attachment.go", "attribute.go", "balconyblock.go", "block.go", "blockgrid.go", "booster.go", "bot.go", "cardinal.go", "chance.go", "collideroptions.go", "color.go", "circle.go", "clock.go", "ctfmode.go", "data.go", "deathmatchmode.go", "equip.go", "equipcharger.go", "expiration.go", "explosion.go", "ffamode.go", "flag.go", "gamemode.go", "grid.go", "health.go", "history.go", "hutblock.go", "init.go", "initprops.go", "input.go", "jetpack.go", "keys.go", "launcher.go", "level.go", "levelfile.go", "light.go", "log.go", "mainblock.go", "msg.go", "object.go", "objectheap.go", "objects.go", "optional.go", "player.go", "profile.go", "profilemath.go", "projectile.go", "projectiles.go", "rec2.go", "replay.go", "roofblock.go", "rotpoly.go", "snapshot.go", "state.go", "stats.go", "structs.go", "subprofile.go", "teamflag.go", "timer.go", "util.go", "validate.go", "vipmode.go", "wall.go", "weapon.go")
 
 foreach ($file in $src_files) {
 	cp "$($file)" "wasm/tmp_$($file)"
 }
 
+Copy-Item -Path "levels" -Destination "wasm/levels" -Recurse -Force
+
 cp "wasm/wasm_main.go" "wasm/wasm_main_copy.txt"
 
 $env:GOOS="js"